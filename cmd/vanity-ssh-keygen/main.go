@@ -1,11 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
+	"net"
 	"os"
 	"os/signal"
 	"runtime"
@@ -28,18 +37,38 @@ import (
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
 
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/certificate"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/checkpoint"
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/drbg"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ecdsa"
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519/edkey"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519/ppk"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/rsa"
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/bitmask"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/dictionary"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/exact"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/exacted25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/expr"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/fingerprint"
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/ignorecase"
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/ignorecaseed25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/multi"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/regex"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/pkcs8"
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/workerpool"
 )
 
 const (
-	serviceName = "vanity-ssh-keygen"
+	serviceName   = "vanity-ssh-keygen"
+	passphraseEnv = "VANITY_SSH_KEYGEN_PASSPHRASE"
 )
 
 var (
@@ -50,32 +79,82 @@ var (
 type Metadata struct {
 	FindString string `json:"findstring"`
 	Time       int64  `json:"time"`
+	// Seeded marks keys that can be reproduced from the seed.
+	Seeded bool `json:"seeded,omitempty"`
+	// Pattern and Offset tell where matchers with several patterns matched.
+	Pattern string `json:"pattern,omitempty"`
+	Offset  int    `json:"offset,omitempty"`
 }
 
 type OutputData struct {
-	PublicKey  string   `json:"public_key"`
-	PrivateKey string   `json:"private_key"` //nolint:gosec // The program is designed to generate private keys.
-	Metadata   Metadata `json:"metadata"`
+	PublicKey   string   `json:"public_key"`
+	PrivateKey  string   `json:"private_key"` //nolint:gosec // The program is designed to generate private keys.
+	Certificate string   `json:"certificate,omitempty"`
+	Metadata    Metadata `json:"metadata"`
 }
 
 type config struct {
-	Version          kong.VersionFlag `help:"Print version and exit"`
-	Debug            bool             `help:"Enable debug logging" default:"false"`
-	MatchString      string           `arg:""`
-	Matcher          string           `help:"Matcher used to find a vanity SSH key. One of: ${matchers}" default:"${default_matcher}" enum:"${matchers}"`
-	KeyType          string           `short:"t" help:"Key type to generate. One of: ${keytypes}" enum:"${keytypes}" default:"${default_keytype}"`
-	Threads          int              `short:"j" help:"Execution threads. Defaults to the number of logical CPU cores" default:"${default_threads}"`
-	Profile          bool             `help:"Profile the process. Write pprof CPU profile to ./pprof" default:"false"`
-	PyroscopeProfile bool             `help:"Profile the process and upload data to Pyroscope" default:"false"`
-	Metrics          bool             `help:"Enable metrics server." default:"false"`
-	OtelLogs         bool             `help:"Enable otel logs." default:"false"`
-	Output           string           `short:"o" help:"Output format. One of: pem-files|json-file." default:"pem-files"`
-	OutputDir        string           `help:"Output directory." default:"./" type:"existingdir"`
-	StatsLogInterval time.Duration    `help:"Statistics will be printed at this interval, set to 0 to disable" default:"2s"`
+	Version            kong.VersionFlag `help:"Print version and exit"`
+	Debug              bool             `help:"Enable debug logging" default:"false"`
+	MatchString        string           `arg:""`
+	Patterns           []string         `name:"pattern" sep:"none" help:"Pattern for the multi matchers, instead of a line of --match-file. Can be repeated."`
+	MatchFile          string           `help:"Read the match string from this file, the argument then only names the output files. Used for matchers with multi-line match strings, like randomart masks. A trailing line break is ignored." type:"existingfile"`
+	Match              string           `help:"Match expression combining matchers with &&, || and !, like 'contains(\"ops\") && suffix(\"42\") && !contains(\"xxx\")'. Used instead of --matcher, the argument then only names the output files. Functions: ${expr_functions}"`
+	Anchor             string           `help:"Where the match string has to be in the public key, checked at a single offset. start is right after the fixed header of the key type, end is before the padding. One of: any,start,end" enum:"any,start,end" default:"any"`
+	Position           *int             `help:"Match this many characters after the start of the key, or before the end with --anchor end. Implies --anchor start."`
+	Matcher            string           `help:"Matcher used to find a vanity SSH key. One of: ${matchers}" default:"${default_matcher}" enum:"${matchers}"`
+	KeyType            string           `short:"t" help:"Key type to generate. One of: ${keytypes}" enum:"${keytypes}" default:"${default_keytype}"`
+	Threads            int              `short:"j" help:"Execution threads. Defaults to the number of logical CPU cores" default:"${default_threads}"`
+	Profile            bool             `help:"Profile the process. Write pprof CPU profile to ./pprof" default:"false"`
+	PyroscopeProfile   bool             `help:"Profile the process and upload data to Pyroscope" default:"false"`
+	Metrics            bool             `help:"Enable metrics server." default:"false"`
+	OtelLogs           bool             `help:"Enable otel logs." default:"false"`
+	Output             string           `short:"o" help:"Output format. One of: pem-files|json-file|ppk|pkcs8|ssh-agent. ssh-agent adds the key to the agent at $SSH_AUTH_SOCK and only writes the public key to disk." default:"pem-files"`
+	OutputDir          string           `help:"Output directory." default:"./" type:"existingdir"`
+	StatsLogInterval   time.Duration    `help:"Statistics will be printed at this interval, set to 0 to disable" default:"2s"`
+	PassphraseFile     string           `help:"Encrypt the private key with the passphrase read from this file. The passphrase can also be set with the ${passphrase_env} environment variable." type:"existingfile"`
+	AskPassphrase      bool             `help:"Prompt for a passphrase to encrypt the private key with." default:"false"`
+	KdfRounds          int              `help:"Number of bcrypt KDF rounds used to encrypt the private key." default:"${default_kdf_rounds}"`
+	RSAFormat          string           `name:"rsa-format" help:"Private key format for RSA keys. Encrypted keys and keys with a comment are always written in OpenSSH format. One of: openssh,pkcs1" enum:"openssh,pkcs1" default:"openssh"`
+	Comment            string           `short:"C" help:"Comment added to the public and private key."`
+	CAKey              string           `name:"ca-key" help:"Sign an OpenSSH certificate for the found key with this CA private key." type:"existingfile"`
+	CertKeyID          string           `name:"cert-key-id" help:"Key identity of the certificate. Defaults to the match string."`
+	CertPrincipals     []string         `name:"cert-principals" help:"Comma separated users or hosts the certificate is valid for."`
+	CertHost           bool             `name:"cert-host" help:"Issue a host certificate instead of a user certificate."`
+	CertSerial         uint64           `name:"cert-serial" help:"Serial number of the certificate."`
+	CertValidity       string           `name:"cert-validity" help:"Validity interval of the certificate in ssh-keygen -V format, e.g. -5m:+52w." default:"always:forever"`
+	CertOptions        []string         `name:"cert-option" sep:"none" help:"Critical option of the certificate as name or name=value, e.g. force-command=/bin/true. Can be repeated."`
+	CertExtensions     []string         `name:"cert-extension" sep:"none" help:"Extension of the certificate as name or name=value. Can be repeated. User certificates get the same extensions as from ssh-keygen by default."`
+	AgentLifetime      time.Duration    `name:"agent-lifetime" help:"Lifetime of the key added to the ssh-agent, 0 for no limit." default:"0"`
+	AgentConfirm       bool             `name:"agent-confirm" help:"Require confirmation every time the key added to the ssh-agent is used." default:"false"`
+	Seed               string           `help:"Generate keys deterministically from this seed, each worker gets its own stream. Seeded keys are reproducible and only meant for tests and audits, do not use them in production."`
+	Checkpoint         string           `help:"Save the search progress to this file, so it can be continued with --resume. Keys are generated from a seed stored in the file, keep it as secret as a private key. The file is removed when a key is found." type:"path"`
+	CheckpointInterval time.Duration    `help:"Interval between saving the search progress." default:"1m"`
+	Resume             bool             `help:"Continue the search saved in the --checkpoint file." default:"false"`
+	Randomart          bool             `help:"Print the randomart of the found key, like ssh-keygen -lv." default:"false"`
+	RSASearch          string           `name:"rsa-search" help:"How RSA candidates are generated. primes generates a new key for every candidate, exponent reuses the primes and steps through the public exponent. One of: primes,exponent" enum:"primes,exponent" default:"primes"`
+	MinWordLength      int              `name:"min-word-length" help:"Minimum number of letters of the words the dictionary matcher accepts." default:"${default_min_word_length}"`
+	Each               bool             `help:"Keep searching until every pattern of a multi matcher has a key. The files of each key are named by its pattern." default:"false"`
+	Deadline           time.Duration    `help:"Stop searching after this long, 0 for no limit. With --each the keys found until then are kept." default:"0"`
+	MaxETA             time.Duration    `name:"max-eta" help:"Refuse searches that are expected to take longer than this, estimated from the rate of the first keys. 0 for no limit." default:"0"`
+	Force              bool             `help:"Search even if the search is expected to take longer than --max-eta." default:"false"`
 }
 
 type app struct {
-	config        config
+	config     config
+	passphrase []byte
+	// locator tells which pattern matched, for matchers with several.
+	locator matcher.Locator
+	// remover drops the patterns that have a key with --each.
+	remover matcher.Remover
+	// matchString is the match string given to the matcher, from the
+	// argument, --match-file, --pattern or --match.
+	matchString string
+	// seed of the workers' key streams, from --seed or the checkpoint.
+	seed          string
+	resume        *checkpoint.State
+	ca            ssh.Signer
+	certOptions   certificate.Options
 	shutdownFuncs []func(context.Context) error
 }
 
@@ -102,25 +181,45 @@ func (a *app) addShutdownFunc(fn func(context.Context) error) {
 }
 
 func main() {
+	var a app
+
 	matcher.RegisterMatcher("ignorecase", ignorecase.New())
 	matcher.RegisterMatcher("ignorecase-ed25519", ignorecaseed25519.New())
-	keygen.RegisterKeygen("ed25519", func() keygen.SSHKey { return ed25519.New() })
-	keygen.RegisterKeygen("rsa-2048", func() keygen.SSHKey { return rsa.New(2048) })
-	keygen.RegisterKeygen("rsa-4096", func() keygen.SSHKey { return rsa.New(4096) })
+	matcher.RegisterMatcher("exact", exact.New())
+	matcher.RegisterMatcher("exact-ed25519", exacted25519.New())
+	matcher.RegisterMatcher("regex", regex.New())
+	matcher.RegisterMatcher("regex-ignorecase", regex.NewIgnoreCase())
+	matcher.RegisterMatcher("multi", multi.New())
+	matcher.RegisterMatcher("multi-ignorecase", multi.NewIgnoreCase())
+	matcher.RegisterMatcher("dictionary", dictionary.New())
+	matcher.RegisterMatcher("bitmask", bitmask.New())
+	matcher.RegisterMatcher("bitmask-ignorecase", bitmask.NewIgnoreCase())
+	matcher.RegisterMatcher("fingerprint-sha256", fingerprint.New())
+	matcher.RegisterMatcher("fingerprint-md5", fingerprint.NewMD5())
+	matcher.RegisterMatcher("randomart", fingerprint.NewRandomart())
+	keygen.RegisterKeygen("ed25519", func(r io.Reader) keygen.SSHKey { return ed25519.New(r) })
+	keygen.RegisterKeygen("rsa-2048", func(r io.Reader) keygen.SSHKey { return a.newRSA(r, 2048) })
+	keygen.RegisterKeygen("rsa-4096", func(r io.Reader) keygen.SSHKey { return a.newRSA(r, 4096) })
+	keygen.RegisterKeygen("ecdsa-p256", func(r io.Reader) keygen.SSHKey { return ecdsa.New(r, elliptic.P256()) })
+	keygen.RegisterKeygen("ecdsa-p384", func(r io.Reader) keygen.SSHKey { return ecdsa.New(r, elliptic.P384()) })
+	keygen.RegisterKeygen("ecdsa-p521", func(r io.Reader) keygen.SSHKey { return ecdsa.New(r, elliptic.P521()) })
 
 	defaultThreads := runtime.NumCPU()
 	overrideThreads := os.Getenv("OVERRIDE_DEFAULT_THREADS")
 	if overrideThreads != "" {
 		defaultThreads, _ = strconv.Atoi(overrideThreads)
 	}
-	var a app
 	_ = kong.Parse(&a.config, kong.Vars{
-		"version":         versionString(),
-		"default_threads": fmt.Sprintf("%d", defaultThreads),
-		"keytypes":        strings.Join(keygen.Names(), ","),
-		"default_keytype": keygen.Names()[0],
-		"matchers":        strings.Join(matcher.Names(), ","),
-		"default_matcher": matcher.Names()[0],
+		"version":                 versionString(),
+		"default_threads":         fmt.Sprintf("%d", defaultThreads),
+		"keytypes":                strings.Join(keygen.Names(), ","),
+		"default_keytype":         keygen.Names()[0],
+		"matchers":                strings.Join(matcher.Names(), ","),
+		"default_matcher":         matcher.Names()[0],
+		"passphrase_env":          passphraseEnv,
+		"default_kdf_rounds":      fmt.Sprintf("%d", edkey.DefaultRounds),
+		"default_min_word_length": fmt.Sprintf("%d", dictionary.DefaultMinLength),
+		"expr_functions":          strings.Join(expr.Functions(), ","),
 	})
 
 	ctx, stop := signal.NotifyContext(context.Background(),
@@ -252,12 +351,102 @@ func main() {
 		slog.Error("Invalid matcher")
 		os.Exit(1)
 	}
-	m.SetMatchString(a.config.MatchString)
+	matchString := a.config.MatchString
+	if a.config.MatchFile != "" {
+		b, err := os.ReadFile(a.config.MatchFile)
+		if err != nil {
+			slog.Error("Could not read match file", "error", err)
+			os.Exit(1)
+		}
+		// Editors end files with a line break, it is not part of the match.
+		matchString = strings.TrimSuffix(strings.TrimSuffix(string(b), "\n"), "\r")
+	}
+	if len(a.config.Patterns) > 0 {
+		if a.config.MatchFile != "" {
+			slog.Error("Use either --pattern or --match-file")
+			os.Exit(1)
+		}
+		matchString = strings.Join(a.config.Patterns, "\n")
+	}
+	if a.config.Match != "" {
+		if a.config.MatchFile != "" || len(a.config.Patterns) > 0 {
+			slog.Error("Use either --match or --match-file and --pattern")
+			os.Exit(1)
+		}
+		m, matchString = expr.New(), a.config.Match
+	}
+	if ml, ok := m.(matcher.MinLength); ok {
+		if a.config.MinWordLength < 1 {
+			slog.Error("--min-word-length must be at least 1")
+			os.Exit(1)
+		}
+		ml.SetMinLength(a.config.MinWordLength)
+	}
+	if err := m.SetMatchString(matchString); err != nil {
+		slog.Error("Invalid match string", "error", err)
+		os.Exit(1)
+	}
+	a.matchString = matchString
 	k, ok := keygen.Get(a.config.KeyType)
 	if !ok {
 		slog.Error("Invalid key type")
 		os.Exit(1)
 	}
+	if l, ok := m.(matcher.Locator); ok {
+		a.locator = l
+	}
+	if err := a.setAnchor(m, k); err != nil {
+		slog.Error("Could not anchor the match string", "error", err)
+		os.Exit(1)
+	}
+	if a.config.Each {
+		r, ok := m.(matcher.Remover)
+		if !ok {
+			slog.Error("--each needs a matcher with several patterns", "matcher", a.config.Matcher)
+			os.Exit(1)
+		}
+		if a.config.Checkpoint != "" {
+			slog.Error("--each can not be combined with --checkpoint")
+			os.Exit(1)
+		}
+		a.remover = r
+	}
+	if s, ok := m.(fmt.Stringer); ok && a.config.Match != "" {
+		slog.Info("Evaluating match expression", "order", s.String())
+	}
+	if d, ok := m.(matcher.Difficulty); ok {
+		slog.Info("Estimated difficulty", "expected_keys", fmt.Sprintf("%.0f", d.Difficulty()))
+	}
+
+	passphrase, err := a.readPassphrase()
+	if err != nil {
+		slog.Error("Could not read passphrase", "error", err)
+		os.Exit(1)
+	}
+	a.passphrase = passphrase
+	if strings.ContainsAny(a.config.Comment, "\r\n") {
+		slog.Error("Comment must be a single line")
+		os.Exit(1)
+	}
+	if a.passphrase != nil || a.config.Comment != "" {
+		if _, ok := k(rand.Reader).(keygen.CryptoKey); !ok {
+			slog.Error("Key type does not support passphrase encryption or comments", "keytype", a.config.KeyType)
+			os.Exit(1)
+		}
+	}
+	if a.config.Output == "ppk" || a.config.Output == "pkcs8" || a.config.Output == "ssh-agent" {
+		if _, ok := k(rand.Reader).(keygen.CryptoKey); !ok {
+			slog.Error("Key type does not support this output format", "keytype", a.config.KeyType, "output", a.config.Output)
+			os.Exit(1)
+		}
+	}
+
+	if a.config.CAKey != "" {
+		if err := a.setupCertificate(); err != nil {
+			slog.Error("Could not set up certificate signing", "error", err)
+			os.Exit(1)
+		}
+	}
 
 	var outputter resultSink
 	switch a.config.Output {
@@ -265,26 +454,110 @@ func main() {
 		outputter = a.outputPEM
 	case "json-file":
 		outputter = a.outputJSON
+	case "ppk":
+		outputter = a.outputPPK
+	case "pkcs8":
+		outputter = a.outputPKCS8
+	case "ssh-agent":
+		// Fail before searching rather than losing the key at the end.
+		conn, err := dialAgent()
+		if err != nil {
+			slog.Error("Could not connect to ssh-agent", "error", err)
+			os.Exit(1)
+		}
+		_ = conn.Close()
+		outputter = a.outputAgent
 	default:
 		slog.Error("Invalid output format", "output", a.config.Output)
 	}
 
-	a.runKeygen(ctx, m, k, outputter)
+	if a.config.Seed != "" {
+		slog.Warn("Generating keys from a seed, anyone who knows the seed can reproduce them. Do not use them in production")
+	}
+	a.seed = a.config.Seed
+	if err := a.setupCheckpoint(); err != nil {
+		slog.Error("Could not set up checkpoint", "error", err)
+		os.Exit(1)
+	}
+
+	if err := a.runKeygen(ctx, m, k, outputter); err != nil {
+		slog.Error("Search stopped", "error", err)
+		a.shutdownAll()
+		os.Exit(1)
+	}
 	a.shutdownAll()
 	os.Exit(0)
 }
 
-func (a *app) runKeygen(ctx context.Context, matcher matcher.Matcher, kg keygen.Keygen, outputter resultSink) {
+// setAnchor gives the layout of the key type to matchers that can use it, and
+// anchors the match string with --anchor and --position.
+func (a *app) setAnchor(m matcher.Matcher, k keygen.Keygen) error {
+	anchor, position := a.anchor()
+	am, ok := m.(matcher.Anchored)
+	l, lok := k(rand.Reader).(keygen.Layouter)
+	if !ok || !lok {
+		if anchor != layout.AnchorAny {
+			return fmt.Errorf("matcher %s or key type %s does not support anchors", a.config.Matcher, a.config.KeyType)
+		}
+		return nil
+	}
+	return am.SetAnchor(l.Layout(), anchor, position)
+}
+
+// anchor returns the anchor and position of --anchor and --position.
+func (a *app) anchor() (layout.Anchor, int) {
+	anchor, position := layout.Anchor(a.config.Anchor), 0
+	if a.config.Position != nil {
+		position = *a.config.Position
+		if anchor == layout.AnchorAny {
+			anchor = layout.AnchorStart
+		}
+	}
+	return anchor, position
+}
+
+func (a *app) newRSA(r io.Reader, bits int) keygen.SSHKey {
+	if a.config.RSASearch == "exponent" {
+		return rsa.NewExponentSearch(r, bits, rsa.Format(a.config.RSAFormat))
+	}
+	return rsa.New(r, bits, rsa.Format(a.config.RSAFormat))
+}
+
+// runKeygen searches until a key is found or ctx is done. It returns an error
+// if the search was stopped by --max-eta.
+func (a *app) runKeygen(ctx context.Context, matcher matcher.Matcher, kg keygen.Keygen, outputter resultSink) error {
+	ctx, cancelCause := context.WithCancelCause(ctx)
+	defer cancelCause(nil)
+	if a.config.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.config.Deadline)
+		defer cancel()
+	}
 	results := make(chan keygen.SSHKey)
 	wp := workerpool.WorkerPool[chan keygen.SSHKey]{
 		Workers: make([]workerpool.Worker[chan keygen.SSHKey], 0, a.config.Threads),
 		Results: results,
 	}
-	for range a.config.Threads {
-		wp.Workers = append(wp.Workers, &keygen.Worker{
+	for i := range a.config.Threads {
+		w := &keygen.Worker{
 			Matchfunc: matcher.Match,
 			Keyfunc:   kg,
-		})
+			ID:        i,
+			Continue:  a.remover != nil,
+		}
+		if a.seed != "" {
+			w.Rand = drbg.New([]byte(a.seed), i)
+		}
+		if a.resume != nil {
+			w.StartAttempt = a.resume.Attempts[i]
+			if len(a.resume.Replay) != 0 {
+				w.Replay = a.resume.Replay[i]
+			}
+		}
+		wp.Workers = append(wp.Workers, w)
+	}
+	if a.resume != nil {
+		wp.ElapsedOffset = a.resume.Elapsed
 	}
 
 	if a.config.StatsLogInterval != 0 {
@@ -294,7 +567,16 @@ func (a *app) runKeygen(ctx context.Context, matcher matcher.Matcher, kg keygen.
 			for {
 				select {
 				case <-ticker.C:
-					wp.GetStats().Log()
+					wps := wp.GetStats()
+					wps.Probability = probability(matcher)
+					wps.Log()
+					if a.remover != nil {
+						remaining := a.remover.Patterns()
+						slog.Info("Remaining patterns",
+							"count", len(remaining),
+							"patterns", strings.Join(remaining, ","),
+						)
+					}
 				case <-ctx.Done():
 					return
 				}
@@ -303,59 +585,593 @@ func (a *app) runKeygen(ctx context.Context, matcher matcher.Matcher, kg keygen.
 	}
 
 	wp.Start(ctx)
+	go a.checkETA(ctx, cancelCause, &wp, matcher)
+	if a.remover != nil {
+		a.collectEach(ctx, &wp, results, outputter)
+		return tooSlow(ctx)
+	}
+	stopCheckpoints := func() {}
+	if a.config.Checkpoint != "" {
+		stopCheckpoints = a.startCheckpoints(&wp)
+	}
 
 	var result keygen.SSHKey
 	select {
 	case result = <-results:
+		stopCheckpoints()
 		wps := wp.GetStats()
 		wps.Log()
+		if a.locator != nil {
+			if pattern, offset, ok := a.locator.Locate(result); ok {
+				slog.Info("Matched pattern", "pattern", pattern, "offset", offset)
+			}
+		}
 		outputter(wps.Elapsed, result)
+		if a.config.Randomart {
+			printRandomart(result)
+		}
+		if a.config.Seed != "" {
+			slog.Warn("The found key was generated from a seed, do not use it in production")
+		}
+		if a.config.Checkpoint != "" {
+			// The checkpoint holds the seed of the found key.
+			if err := os.Remove(a.config.Checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+				slog.Error("Could not remove checkpoint", "error", err)
+			}
+		}
 	case <-ctx.Done():
-		slog.Info("Cancellation received, exiting...")
+		switch {
+		case tooSlow(ctx) != nil:
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			slog.Info("Deadline passed, exiting...")
+		default:
+			slog.Info("Cancellation received, exiting...")
+		}
+		if a.config.Checkpoint != "" {
+			stopCheckpoints()
+			a.saveCheckpoint(&wp)
+			slog.Info("Search progress saved, continue with --resume", "checkpoint", a.config.Checkpoint)
+		}
+	}
+	return tooSlow(ctx)
+}
+
+// errTooSlow is the cause of a search stopped by --max-eta.
+var errTooSlow = errors.New("the search is expected to take longer than --max-eta, use --force to search anyway")
+
+// etaWarmup is how long keys are tested before the search time is estimated.
+var etaWarmup = time.Second
+
+// tooSlow returns the error if ctx was canceled by --max-eta.
+func tooSlow(ctx context.Context) error {
+	if err := context.Cause(ctx); errors.Is(err, errTooSlow) {
+		return err
+	}
+	return nil
+}
+
+// probability returns the probability that a key matches, 0 if the matcher
+// can not estimate it.
+func probability(m matcher.Matcher) float64 {
+	d, ok := m.(matcher.Difficulty)
+	if !ok {
+		return 0
+	}
+	if keys := d.Difficulty(); keys > 0 {
+		return min(1/keys, 1)
+	}
+	return 0
+}
+
+// checkETA logs the expected search time once the rate is measured, and
+// cancels the search if it is longer than --max-eta.
+func (a *app) checkETA(ctx context.Context, cancel context.CancelCauseFunc, wp *workerpool.WorkerPool[chan keygen.SSHKey], m matcher.Matcher) {
+	ticker := time.NewTicker(etaWarmup)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		wps := wp.GetStats()
+		wps.Probability = probability(m)
+		if wps.Probability == 0 {
+			return
+		}
+		if wps.Count == 0 {
+			// Slow key types need longer for the first keys.
+			continue
+		}
+		expected := wps.ExpectedTime().Round(time.Second)
+		slog.Info("Estimated search time",
+			slog.Duration("expected", expected),
+			slog.Duration("eta_p50", wps.ETA(0.5).Round(time.Second)),
+			slog.Duration("eta_p90", wps.ETA(0.9).Round(time.Second)),
+			slog.Float64("kKeys/s", wps.Rate()/1000),
+		)
+		if a.config.MaxETA > 0 && expected > a.config.MaxETA && !a.config.Force {
+			cancel(fmt.Errorf("%w: expected %v at %.0f keys/s", errTooSlow, expected, wps.Rate()))
+		}
+		return
+	}
+}
+
+// collectEach outputs one key for every pattern of the remover, until all
+// patterns have a key or ctx is done. The workers keep searching after a
+// result, so keys for other patterns are not thrown away.
+func (a *app) collectEach(ctx context.Context, wp *workerpool.WorkerPool[chan keygen.SSHKey], results chan keygen.SSHKey, outputter resultSink) {
+	for len(a.remover.Patterns()) > 0 {
+		select {
+		case result := <-results:
+			// The pattern is removed after the output, so the outputs can
+			// still locate it.
+			pattern, offset, ok := a.remover.Locate(result)
+			if !ok {
+				// Another worker found a key for the pattern first.
+				continue
+			}
+			wps := wp.GetStats()
+			slog.Info("Matched pattern", "pattern", pattern, "offset", offset)
+			outputter(wps.Elapsed, result)
+			if a.config.Randomart {
+				printRandomart(result)
+			}
+			a.remover.Remove(pattern)
+		case <-ctx.Done():
+			remaining := a.remover.Patterns()
+			slog.Info("Search stopped before every pattern had a key",
+				"count", len(remaining),
+				"patterns", strings.Join(remaining, ","),
+			)
+			return
+		}
+	}
+	wp.GetStats().Log()
+	slog.Info("Found a key for every pattern")
+	if a.config.Seed != "" {
+		slog.Warn("The found keys were generated from a seed, do not use them in production")
+	}
+}
+
+// fileNameReplacer escapes the path separators of a pattern, '/' is a base64
+// character. '_' is not, so escaped names do not collide with other patterns.
+var fileNameReplacer = strings.NewReplacer("/", "_", `\`, "_")
+
+// name returns the name of the output files of result. With --each it is the
+// pattern the key was found for.
+func (a *app) name(result keygen.SSHKey) string {
+	if a.remover != nil {
+		if pattern, _, ok := a.remover.Locate(result); ok {
+			return fileNameReplacer.Replace(pattern)
+		}
+	}
+	return fileNameReplacer.Replace(a.config.MatchString)
+}
+
+// printRandomart writes the randomart of the result to stderr, after the log
+// of the found key.
+func printRandomart(result keygen.SSHKey) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(result.SSHPubkey())
+	if err != nil {
+		slog.Error("Could not parse public key", "error", err)
+		return
+	}
+	fmt.Fprint(os.Stderr, fingerprint.KeyRandomart(pub))
+}
+
+// search identifies the search in checkpoints. The match string can be a
+// whole wordlist, only its hash is saved.
+func (a *app) search() checkpoint.Search {
+	anchor, position := a.anchor()
+	sum := sha256.Sum256([]byte(a.matchString))
+	return checkpoint.Search{
+		MatchString:   a.config.MatchString,
+		MatchHash:     hex.EncodeToString(sum[:]),
+		Matcher:       a.config.Matcher,
+		Expression:    a.config.Match,
+		KeyType:       a.config.KeyType,
+		RSASearch:     a.config.RSASearch,
+		Anchor:        string(anchor),
+		Position:      position,
+		MinWordLength: a.config.MinWordLength,
+	}
+}
+
+// setupCheckpoint loads the checkpoint to resume from, or picks a random seed
+// for a new checkpointed search, since only seeded searches can be resumed.
+func (a *app) setupCheckpoint() error {
+	if a.config.Checkpoint == "" {
+		if a.config.Resume {
+			return errors.New("--resume needs --checkpoint")
+		}
+		return nil
+	}
+	if a.config.CheckpointInterval <= 0 {
+		return errors.New("--checkpoint-interval must be positive")
+	}
+
+	if a.config.Resume {
+		state, err := checkpoint.Load(a.config.Checkpoint)
+		if err != nil {
+			return err
+		}
+		if err := state.Check(a.search()); err != nil {
+			return err
+		}
+		if a.config.Seed != "" && a.config.Seed != state.Seed {
+			return errors.New("--seed differs from the seed in the checkpoint")
+		}
+		a.seed = state.Seed
+		a.resume = state
+		if a.config.Threads != len(state.Attempts) {
+			slog.Info("Using the number of threads from the checkpoint", "threads", len(state.Attempts))
+			a.config.Threads = len(state.Attempts)
+		}
+		return nil
+	}
+
+	if _, err := os.Stat(a.config.Checkpoint); err == nil {
+		return fmt.Errorf("%s exists, continue the search with --resume", a.config.Checkpoint)
+	}
+	if a.seed == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		a.seed = hex.EncodeToString(b)
+	}
+	return nil
+}
+
+// startCheckpoints saves the search progress at every interval. The returned
+// function stops saving, it returns once no save is in progress.
+func (a *app) startCheckpoints(wp *workerpool.WorkerPool[chan keygen.SSHKey]) func() {
+	ticker := time.NewTicker(a.config.CheckpointInterval)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				a.saveCheckpoint(wp)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+func (a *app) saveCheckpoint(wp *workerpool.WorkerPool[chan keygen.SSHKey]) {
+	state := checkpoint.State{
+		Search:   a.search(),
+		Seed:     a.seed,
+		Attempts: make([]int64, len(wp.Workers)),
+		Replay:   make([]int64, len(wp.Workers)),
+		Elapsed:  wp.GetStats().Elapsed,
+	}
+	for i, w := range wp.Workers {
+		var start int64
+		if a.resume != nil {
+			start = a.resume.Attempts[i]
+		}
+		// Loaded before the count, the stream is never after the saved
+		// attempt.
+		stream := int64(math.MaxInt64)
+		if kw, ok := w.(*keygen.Worker); ok {
+			stream = kw.Stream()
+		}
+		// The last attempt may still be checked, repeat it when resuming.
+		state.Attempts[i] = max(w.Count()-1, start)
+		state.Replay[i] = state.Attempts[i] - min(stream, state.Attempts[i])
+	}
+	if err := state.Save(a.config.Checkpoint); err != nil {
+		slog.Error("Could not save checkpoint", "error", err)
+		return
 	}
+	slog.Debug("Checkpoint saved", "checkpoint", a.config.Checkpoint)
+}
+
+// readPassphrase returns the passphrase the private key should be encrypted
+// with, or nil if it should be stored unencrypted.
+func (a *app) readPassphrase() ([]byte, error) {
+	var passphrase []byte
+	switch {
+	case a.config.PassphraseFile != "":
+		b, err := os.ReadFile(a.config.PassphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		// Only strip the line ending, whitespace may be part of the passphrase.
+		passphrase = bytes.TrimSuffix(bytes.TrimSuffix(b, []byte("\n")), []byte("\r"))
+	case os.Getenv(passphraseEnv) != "":
+		passphrase = []byte(os.Getenv(passphraseEnv))
+	case a.config.AskPassphrase:
+		b, err := promptPassphrase()
+		if err != nil {
+			return nil, err
+		}
+		passphrase = b
+	default:
+		return nil, nil
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	return passphrase, nil
+}
+
+func promptPassphrase() ([]byte, error) {
+	passphrase, err := readTerminal("Enter passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	confirm, err := readTerminal("Enter same passphrase again: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(passphrase, confirm) {
+		return nil, errors.New("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// readTerminal prompts for a line on the terminal without echoing it.
+func readTerminal(prompt string) ([]byte, error) {
+	fd := int(os.Stdin.Fd()) //nolint:gosec // File descriptors fit in an int.
+	if !term.IsTerminal(fd) {
+		return nil, errors.New("stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return b, err
+}
+
+// setupCertificate loads the certificate authority and validates the
+// certificate options, so that mistakes are reported before searching.
+func (a *app) setupCertificate() error {
+	b, err := os.ReadFile(a.config.CAKey)
+	if err != nil {
+		return err
+	}
+	a.ca, err = ssh.ParsePrivateKey(b)
+	if _, ok := errors.AsType[*ssh.PassphraseMissingError](err); ok {
+		passphrase, perr := readTerminal("Enter passphrase for CA key: ")
+		if perr != nil {
+			return perr
+		}
+		a.ca, err = ssh.ParsePrivateKeyWithPassphrase(b, passphrase)
+	}
+	if err != nil {
+		return fmt.Errorf("could not parse CA key: %w", err)
+	}
+
+	if _, _, err := certificate.ParseValidity(a.config.CertValidity, time.Now()); err != nil {
+		return err
+	}
+	criticalOptions, err := certificate.ParseOptions(a.config.CertOptions)
+	if err != nil {
+		return err
+	}
+	extensions, err := certificate.ParseOptions(a.config.CertExtensions)
+	if err != nil {
+		return err
+	}
+	keyID := a.config.CertKeyID
+	if keyID == "" {
+		keyID = a.config.MatchString
+	}
+	a.certOptions = certificate.Options{
+		KeyID:           keyID,
+		Principals:      a.config.CertPrincipals,
+		Host:            a.config.CertHost,
+		Serial:          a.config.CertSerial,
+		CriticalOptions: criticalOptions,
+		Extensions:      extensions,
+	}
+	return nil
+}
+
+// certificate returns a certificate for result in authorized_keys format, or
+// nil if no certificate authority is configured.
+func (a *app) certificate(result keygen.SSHKey) ([]byte, error) {
+	if a.ca == nil {
+		return nil, nil
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(result.SSHPubkey())
+	if err != nil {
+		return nil, err
+	}
+	// Relative times are relative to when the certificate is signed.
+	opts := a.certOptions
+	opts.ValidAfter, opts.ValidBefore, err = certificate.ParseValidity(a.config.CertValidity, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	cert, err := certificate.Sign(pub, a.ca, opts)
+	if err != nil {
+		return nil, err
+	}
+	return a.withComment(ssh.MarshalAuthorizedKey(cert)), nil
+}
+
+// pubkey returns the public key of result in authorized_keys format, with the
+// configured comment.
+func (a *app) pubkey(result keygen.SSHKey) []byte {
+	return a.withComment(result.SSHPubkey())
+}
+
+// withComment appends the configured comment to an authorized_keys line.
+func (a *app) withComment(pubK []byte) []byte {
+	if a.config.Comment == "" {
+		return pubK
+	}
+	// The key owns its buffer, so build the line in a new slice.
+	line := make([]byte, 0, len(pubK)+1+len(a.config.Comment)+1)
+	line = append(line, bytes.TrimSuffix(pubK, []byte("\n"))...)
+	line = append(line, ' ')
+	line = append(line, a.config.Comment...)
+	return append(line, '\n')
+}
+
+// privkey returns the private key of result in PEM format, encrypted if a
+// passphrase is configured.
+func (a *app) privkey(result keygen.SSHKey) ([]byte, error) {
+	if a.passphrase == nil && a.config.Comment == "" {
+		return result.SSHPrivkey(), nil
+	}
+	k, ok := result.(keygen.CryptoKey)
+	if !ok {
+		return nil, errors.New("key type does not support passphrase encryption or comments")
+	}
+	privDER, err := edkey.MarshalPrivateKey(k.PrivateKey(), edkey.Options{
+		Passphrase: a.passphrase,
+		Rounds:     a.config.KdfRounds,
+		Comment:    a.config.Comment,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: privDER,
+	}), nil
 }
 
 func (a *app) outputPEM(elapsed time.Duration, result keygen.SSHKey) {
-	privK := result.SSHPrivkey()
-	pubK := result.SSHPubkey()
+	slog.Info("Found matching public key", "pubkey", string(a.pubkey(result)))
+	a.writePEM(elapsed, result)
+}
+
+// writePEM writes the private key, public key and certificate files of result.
+func (a *app) writePEM(elapsed time.Duration, result keygen.SSHKey) {
+	pubK := a.pubkey(result)
+	outDir := a.config.OutputDir + "/"
+	name := a.name(result)
+	privK, err := a.privkey(result)
+	if err != nil {
+		slog.Error("Could not encode private key", "error", err)
+		return
+	}
+
+	privkeyFileName := outDir + name
+	pubkeyFileName := outDir + name + ".pub"
+	if err := os.WriteFile(privkeyFileName, privK, 0o600); err != nil {
+		slog.Error("Could not write private key file", "error", err)
+	}
+	if err := os.WriteFile(pubkeyFileName, pubK, 0o600); err != nil {
+		slog.Error("Could not write public key file", "error", err)
+	}
+	attrs := []any{
+		"privkey_file", privkeyFileName,
+		"pubkey_file", pubkeyFileName,
+	}
+	_, certAttrs := a.writeCertificate(outDir, result)
+	attrs = append(attrs, certAttrs...)
+	slog.Info("Result keypair stored", append(attrs, slog.Duration("elapsed", elapsed))...)
+}
+
+// writeCertificate writes the certificate for result next to the public key,
+// if a certificate authority is configured. It returns the certificate and the
+// log attributes for the written file.
+func (a *app) writeCertificate(outDir string, result keygen.SSHKey) ([]byte, []any) {
+	cert, err := a.certificate(result)
+	if err != nil {
+		slog.Error("Could not sign certificate", "error", err)
+	}
+	if cert == nil {
+		return nil, nil
+	}
+	certFileName := outDir + a.name(result) + "-cert.pub"
+	if err := os.WriteFile(certFileName, cert, 0o600); err != nil {
+		slog.Error("Could not write certificate file", "error", err)
+	}
+	return cert, []any{"cert_file", certFileName}
+}
+
+func (a *app) outputPPK(elapsed time.Duration, result keygen.SSHKey) {
+	pubK := a.pubkey(result)
 	slog.Info("Found matching public key", "pubkey", string(pubK))
 	outDir := a.config.OutputDir + "/"
+	name := a.name(result)
+	k, ok := result.(keygen.CryptoKey)
+	if !ok {
+		slog.Error("Key type does not support PuTTY output")
+		return
+	}
+	privK, err := ppk.MarshalPrivateKey(k.PrivateKey(), ppk.Options{
+		Passphrase: a.passphrase,
+		Comment:    a.config.Comment,
+	})
+	if err != nil {
+		slog.Error("Could not encode private key", "error", err)
+		return
+	}
 
-	privkeyFileName := outDir + a.config.MatchString
-	pubkeyFileName := outDir + a.config.MatchString + ".pub"
+	privkeyFileName := outDir + name + ".ppk"
+	pubkeyFileName := outDir + name + ".pub"
 	if err := os.WriteFile(privkeyFileName, privK, 0o600); err != nil {
 		slog.Error("Could not write private key file", "error", err)
 	}
 	if err := os.WriteFile(pubkeyFileName, pubK, 0o600); err != nil {
 		slog.Error("Could not write public key file", "error", err)
 	}
-	slog.Info("Result keypair stored",
+	attrs := []any{
 		"privkey_file", privkeyFileName,
 		"pubkey_file", pubkeyFileName,
-		slog.Duration("elapsed", elapsed),
-	)
+	}
+	_, certAttrs := a.writeCertificate(outDir, result)
+	attrs = append(attrs, certAttrs...)
+	slog.Info("Result keypair stored", append(attrs, slog.Duration("elapsed", elapsed))...)
 }
 
 func (a *app) outputJSON(elapsed time.Duration, result keygen.SSHKey) {
-	privK := result.SSHPrivkey()
-	pubK := result.SSHPubkey()
+	pubK := a.pubkey(result)
 	slog.Info("Found matching public key", "pubkey", string(pubK))
 	outDir := a.config.OutputDir + "/"
+	privK, err := a.privkey(result)
+	if err != nil {
+		slog.Error("Could not encode private key", "error", err)
+		return
+	}
+
+	cert, err := a.certificate(result)
+	if err != nil {
+		slog.Error("Could not sign certificate", "error", err)
+	}
 
+	metadata := Metadata{
+		FindString: a.config.MatchString,
+		Time:       int64(elapsed / time.Second),
+		Seeded:     a.config.Seed != "",
+	}
+	if a.locator != nil {
+		if pattern, offset, ok := a.locator.Locate(result); ok {
+			metadata.Pattern, metadata.Offset = pattern, offset
+		}
+	}
 	//nolint:gosec // The program is designed to generate private keys.
 	file, err := json.MarshalIndent(OutputData{
-		PublicKey:  string(pubK),
-		PrivateKey: string(privK),
-		Metadata: Metadata{
-			FindString: a.config.MatchString,
-			Time:       int64(elapsed / time.Second),
-		},
+		PublicKey:   string(pubK),
+		PrivateKey:  string(privK),
+		Certificate: string(cert),
+		Metadata:    metadata,
 	}, "", " ")
 	if err != nil {
 		slog.Error("Could not marshal result to JSON", "error", err)
 		return
 	}
 	jsonFileName := outDir + "result.json"
+	if a.remover != nil {
+		jsonFileName = outDir + a.name(result) + ".json"
+	}
 	if err := os.WriteFile(jsonFileName, file, 0o600); err != nil {
 		slog.Error("Could not write result JSON file", "error", err)
 	}
@@ -385,3 +1201,120 @@ func versionString() string {
 		runtime.GOARCH,
 	)
 }
+
+// outputPKCS8 writes the private key as PKCS#8 and the public key as
+// SubjectPublicKeyInfo PEM, for TLS and JOSE libraries, next to the SSH public
+// key.
+func (a *app) outputPKCS8(elapsed time.Duration, result keygen.SSHKey) {
+	pubK := a.pubkey(result)
+	slog.Info("Found matching public key", "pubkey", string(pubK))
+	outDir := a.config.OutputDir + "/"
+	name := a.name(result)
+	k, ok := result.(keygen.CryptoKey)
+	if !ok {
+		slog.Error("Key type does not support PKCS#8 output")
+		return
+	}
+	privK, err := pkcs8.MarshalPrivateKey(k.PrivateKey(), pkcs8.Options{Passphrase: a.passphrase})
+	if err != nil {
+		slog.Error("Could not encode private key", "error", err)
+		return
+	}
+	spki, err := pkcs8.MarshalPublicKey(k.PrivateKey().Public())
+	if err != nil {
+		slog.Error("Could not encode public key", "error", err)
+		return
+	}
+
+	privkeyFileName := outDir + name + ".key"
+	spkiFileName := outDir + name + ".pub.pem"
+	pubkeyFileName := outDir + name + ".pub"
+	if err := os.WriteFile(privkeyFileName, privK, 0o600); err != nil {
+		slog.Error("Could not write private key file", "error", err)
+	}
+	if err := os.WriteFile(spkiFileName, spki, 0o600); err != nil {
+		slog.Error("Could not write public key file", "error", err)
+	}
+	if err := os.WriteFile(pubkeyFileName, pubK, 0o600); err != nil {
+		slog.Error("Could not write public key file", "error", err)
+	}
+	attrs := []any{
+		"privkey_file", privkeyFileName,
+		"spki_file", spkiFileName,
+		"pubkey_file", pubkeyFileName,
+	}
+	_, certAttrs := a.writeCertificate(outDir, result)
+	attrs = append(attrs, certAttrs...)
+	slog.Info("Result keypair stored", append(attrs, slog.Duration("elapsed", elapsed))...)
+}
+
+func dialAgent() (net.Conn, error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, errors.New("SSH_AUTH_SOCK is not set")
+	}
+	return net.Dial("unix", sock)
+}
+
+// outputAgent adds the private key to the running ssh-agent, so it is never
+// written to disk. Only the public key and certificate are written. If the
+// agent can not take the key, it is written to a file instead of being lost,
+// encrypted if a passphrase is given.
+func (a *app) outputAgent(elapsed time.Duration, result keygen.SSHKey) {
+	pubK := a.pubkey(result)
+	slog.Info("Found matching public key", "pubkey", string(pubK))
+	outDir := a.config.OutputDir + "/"
+	name := a.name(result)
+	k, ok := result.(keygen.CryptoKey)
+	if !ok {
+		slog.Error("Key type does not support ssh-agent output")
+		return
+	}
+
+	comment := a.config.Comment
+	if comment == "" {
+		comment = name
+	}
+	key := agent.AddedKey{
+		PrivateKey:       k.PrivateKey(),
+		Comment:          comment,
+		LifetimeSecs:     uint32(a.config.AgentLifetime / time.Second), //nolint:gosec // Lifetimes do not overflow.
+		ConfirmBeforeUse: a.config.AgentConfirm,
+	}
+
+	conn, err := dialAgent()
+	if err != nil {
+		slog.Error("Could not connect to ssh-agent, writing the key to a file instead", "error", err)
+		a.writePEM(elapsed, result)
+		return
+	}
+	defer conn.Close()
+	client := agent.NewClient(conn)
+	if err := client.Add(key); err != nil {
+		slog.Error("Could not add key to ssh-agent, writing the key to a file instead", "error", err)
+		a.writePEM(elapsed, result)
+		return
+	}
+
+	pubkeyFileName := outDir + name + ".pub"
+	if err := os.WriteFile(pubkeyFileName, pubK, 0o600); err != nil {
+		slog.Error("Could not write public key file", "error", err)
+	}
+	cert, certAttrs := a.writeCertificate(outDir, result)
+	attrs := append([]any{"pubkey_file", pubkeyFileName}, certAttrs...)
+
+	// Like ssh-add, add the certificate as a second identity next to the
+	// plain key.
+	if cert != nil {
+		pub, _, _, _, err := ssh.ParseAuthorizedKey(cert)
+		if err != nil {
+			slog.Error("Could not parse certificate", "error", err)
+		} else {
+			key.Certificate, _ = pub.(*ssh.Certificate)
+			if err := client.Add(key); err != nil {
+				slog.Error("Could not add certificate to ssh-agent", "error", err)
+			}
+		}
+	}
+	slog.Info("Result key added to ssh-agent", append(attrs, slog.Duration("elapsed", elapsed))...)
+}
@@ -2,14 +2,31 @@ package main
 
 import (
 	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/checkpoint"
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/drbg"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/rsa"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/exact"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/multi"
 )
 
 type mockKey struct {
@@ -95,6 +112,394 @@ func TestOutputJSON(t *testing.T) {
 	}
 }
 
+func TestOutputJSONPattern(t *testing.T) {
+	tmpDir := t.TempDir()
+	m := multi.New()
+	if err := m.SetMatchString("alice\nbob"); err != nil {
+		t.Fatal(err)
+	}
+	a := &app{
+		config: config{
+			MatchString: "team",
+			OutputDir:   tmpDir,
+		},
+		locator: m,
+	}
+
+	a.outputJSON(time.Second, &mockKey{pub: []byte("ssh-ed25519 AAAAbob\n")})
+
+	//nolint:gosec // G304: Path is controlled by the test via t.TempDir()
+	content, err := os.ReadFile(filepath.Join(tmpDir, "result.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out OutputData
+	if err := json.Unmarshal(content, &out); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+	if out.Metadata.Pattern != "bob" || out.Metadata.Offset != 16 {
+		t.Errorf("Expected pattern bob at 16, got %+v", out.Metadata)
+	}
+}
+
+func TestReadPassphrase(t *testing.T) {
+	passphraseFile := filepath.Join(t.TempDir(), "passphrase")
+	if err := os.WriteFile(passphraseFile, []byte(" secret \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &app{config: config{PassphraseFile: passphraseFile}}
+	passphrase, err := a.readPassphrase()
+	if err != nil {
+		t.Fatalf("readPassphrase failed: %v", err)
+	}
+	if string(passphrase) != " secret " {
+		t.Errorf("Unexpected passphrase from file: %q", passphrase)
+	}
+
+	t.Setenv(passphraseEnv, "from-env")
+	a = &app{}
+	passphrase, err = a.readPassphrase()
+	if err != nil {
+		t.Fatalf("readPassphrase failed: %v", err)
+	}
+	if string(passphrase) != "from-env" {
+		t.Errorf("Unexpected passphrase from environment: %q", passphrase)
+	}
+
+	t.Setenv(passphraseEnv, "")
+	passphrase, err = a.readPassphrase()
+	if err != nil || passphrase != nil {
+		t.Errorf("Expected no passphrase, got %q, %v", passphrase, err)
+	}
+}
+
+func TestOutputPEMPassphrase(t *testing.T) {
+	tmpDir := t.TempDir()
+	a := &app{
+		config: config{
+			MatchString: "test",
+			OutputDir:   tmpDir,
+			KdfRounds:   4,
+		},
+		passphrase: []byte("secret"),
+	}
+
+	key := ed25519.New(rand.Reader)
+	key.Generate()
+	a.outputPEM(1*time.Second, key)
+
+	//nolint:gosec // G304: Path is controlled by the test via t.TempDir()
+	content, err := os.ReadFile(filepath.Join(tmpDir, "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ssh.ParseRawPrivateKey(content); err == nil {
+		t.Error("Expected private key to be encrypted")
+	}
+	if _, err := ssh.ParseRawPrivateKeyWithPassphrase(content, []byte("secret")); err != nil {
+		t.Errorf("Failed to decrypt private key: %v", err)
+	}
+}
+
+func TestOutputPEMComment(t *testing.T) {
+	tmpDir := t.TempDir()
+	a := &app{
+		config: config{
+			MatchString: "test",
+			OutputDir:   tmpDir,
+			Comment:     "alice@corp",
+		},
+	}
+
+	key := ed25519.New(rand.Reader)
+	key.Generate()
+	a.outputPEM(1*time.Second, key)
+
+	//nolint:gosec // G304: Path is controlled by the test via t.TempDir()
+	pub, err := os.ReadFile(filepath.Join(tmpDir, "test.pub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, comment, _, _, err := ssh.ParseAuthorizedKey(pub)
+	if err != nil {
+		t.Fatalf("Failed to parse public key: %v", err)
+	}
+	if comment != "alice@corp" {
+		t.Errorf("Unexpected public key comment: %q", comment)
+	}
+	if len(key.SSHPubkey()) != 81 {
+		t.Errorf("Comment must not change the key's own public key buffer")
+	}
+
+	//nolint:gosec // G304: Path is controlled by the test via t.TempDir()
+	priv, err := os.ReadFile(filepath.Join(tmpDir, "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ssh.ParseRawPrivateKey(priv); err != nil {
+		t.Errorf("Failed to parse private key: %v", err)
+	}
+}
+
+func TestOutputCertificate(t *testing.T) {
+	tmpDir := t.TempDir()
+	ca := ed25519.New(rand.Reader)
+	ca.Generate()
+	caFile := filepath.Join(tmpDir, "ca")
+	if err := os.WriteFile(caFile, ca.SSHPrivkey(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &app{
+		config: config{
+			MatchString:    "test",
+			OutputDir:      tmpDir,
+			CAKey:          caFile,
+			CertPrincipals: []string{"alice"},
+			CertValidity:   "always:forever",
+		},
+	}
+	if err := a.setupCertificate(); err != nil {
+		t.Fatal(err)
+	}
+
+	key := ed25519.New(rand.Reader)
+	key.Generate()
+	a.outputPEM(1*time.Second, key)
+
+	//nolint:gosec // G304: Path is controlled by the test via t.TempDir()
+	b, err := os.ReadFile(filepath.Join(tmpDir, "test-cert.pub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCertificate(t, b, key, ca)
+
+	a.outputJSON(1*time.Second, key)
+	//nolint:gosec // G304: Path is controlled by the test via t.TempDir()
+	b, err = os.ReadFile(filepath.Join(tmpDir, "result.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data OutputData
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	checkCertificate(t, []byte(data.Certificate), key, ca)
+}
+
+func checkCertificate(t *testing.T, b []byte, key, ca keygen.SSHKey) {
+	t.Helper()
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(b)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		t.Fatalf("Expected a certificate, got %T", pub)
+	}
+	if cert.KeyId != "test" || len(cert.ValidPrincipals) != 1 || cert.ValidPrincipals[0] != "alice" {
+		t.Errorf("Unexpected certificate key id %q or principals %v", cert.KeyId, cert.ValidPrincipals)
+	}
+	if string(ssh.MarshalAuthorizedKey(cert.Key)) != string(key.SSHPubkey()) {
+		t.Errorf("Certificate is not for the found key")
+	}
+	caPub, _, _, _, err := ssh.ParseAuthorizedKey(ca.SSHPubkey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(caPub.Marshal())
+		},
+	}
+	if err := checker.CheckCert("alice", cert); err != nil {
+		t.Errorf("Certificate does not verify: %v", err)
+	}
+}
+
+func TestOutputPPK(t *testing.T) {
+	tmpDir := t.TempDir()
+	a := &app{
+		config: config{
+			MatchString: "test",
+			OutputDir:   tmpDir,
+			Comment:     "alice@corp",
+		},
+	}
+
+	key := ed25519.New(rand.Reader)
+	key.Generate()
+	a.outputPPK(1*time.Second, key)
+
+	//nolint:gosec // G304: Path is controlled by the test via t.TempDir()
+	priv, err := os.ReadFile(filepath.Join(tmpDir, "test.ppk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(priv), "PuTTY-User-Key-File-3: ssh-ed25519\nEncryption: none\nComment: alice@corp\n") {
+		t.Errorf("Unexpected PPK header: %q", priv)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "test.pub")); err != nil {
+		t.Errorf("Public key file not written: %v", err)
+	}
+}
+
+func TestOutputPKCS8(t *testing.T) {
+	tmpDir := t.TempDir()
+	a := &app{
+		config: config{
+			MatchString: "test",
+			OutputDir:   tmpDir,
+		},
+	}
+
+	key := ed25519.New(rand.Reader)
+	key.Generate()
+	a.outputPKCS8(1*time.Second, key)
+
+	//nolint:gosec // G304: Path is controlled by the test via t.TempDir()
+	priv, err := os.ReadFile(filepath.Join(tmpDir, "test.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(priv)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		t.Fatal("Expected a PRIVATE KEY PEM block")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//nolint:gosec // G304: Path is controlled by the test via t.TempDir()
+	spki, err := os.ReadFile(filepath.Join(tmpDir, "test.pub.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ = pem.Decode(spki)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		t.Fatal("Expected a PUBLIC KEY PEM block")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.(crypto.Signer).Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(pub) {
+		t.Error("Public key does not match the private key")
+	}
+
+	//nolint:gosec // G304: Path is controlled by the test via t.TempDir()
+	sshPub, err := os.ReadFile(filepath.Join(tmpDir, "test.pub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(sshPub) != string(key.SSHPubkey()) {
+		t.Errorf("Unexpected SSH public key %q", sshPub)
+	}
+}
+
+// serveKeyring serves an in-process agent on a temporary unix socket and
+// points SSH_AUTH_SOCK at it.
+func serveKeyring(t *testing.T) agent.Agent {
+	t.Helper()
+	keyring := agent.NewKeyring()
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = agent.ServeAgent(keyring, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", sock)
+	return keyring
+}
+
+func TestOutputAgent(t *testing.T) {
+	keyring := serveKeyring(t)
+	tmpDir := t.TempDir()
+	a := &app{
+		config: config{
+			MatchString:   "test",
+			OutputDir:     tmpDir,
+			AgentLifetime: time.Hour,
+		},
+	}
+
+	key := ed25519.New(rand.Reader)
+	key.Generate()
+	a.outputAgent(1*time.Second, key)
+
+	keys, err := keyring.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("Expected 1 key in the agent, got %d", len(keys))
+	}
+	if keys[0].Comment != "test" {
+		t.Errorf("Unexpected comment %q", keys[0].Comment)
+	}
+	if string(ssh.MarshalAuthorizedKey(keys[0])) != string(key.SSHPubkey()) {
+		t.Error("Agent holds a different key")
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "test.pub" {
+		t.Errorf("Only the public key should be written, got %v", entries)
+	}
+}
+
+func TestOutputAgentFallback(t *testing.T) {
+	keyring := serveKeyring(t)
+	if err := keyring.Lock([]byte("agent")); err != nil {
+		t.Fatal(err)
+	}
+	for name, setup := range map[string]func(t *testing.T){
+		"no agent":     func(t *testing.T) { t.Setenv("SSH_AUTH_SOCK", "") },
+		"locked agent": func(*testing.T) {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			setup(t)
+			tmpDir := t.TempDir()
+			a := &app{
+				config:     config{MatchString: "test", OutputDir: tmpDir, KdfRounds: 1},
+				passphrase: []byte("secret"),
+			}
+			key := ed25519.New(rand.Reader)
+			key.Generate()
+			a.outputAgent(1*time.Second, key)
+
+			// The key is not lost, and encrypted with the passphrase.
+			//nolint:gosec // G304: Path is controlled by the test via t.TempDir()
+			privK, err := os.ReadFile(filepath.Join(tmpDir, "test"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ssh.ParseRawPrivateKey(privK); !errors.As(err, new(*ssh.PassphraseMissingError)) {
+				t.Errorf("Expected an encrypted private key, got %v", err)
+			}
+			if _, err := ssh.ParseRawPrivateKeyWithPassphrase(privK, []byte("secret")); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
 func TestRunKeygen(t *testing.T) {
 	a := &app{
 		config: config{
@@ -104,7 +509,7 @@ func TestRunKeygen(t *testing.T) {
 	}
 
 	mockM := &mockMatcher{match: true}
-	mockK := func() keygen.SSHKey {
+	mockK := func(io.Reader) keygen.SSHKey {
 		return &mockKey{pub: []byte("match"), priv: []byte("priv")}
 	}
 
@@ -113,7 +518,9 @@ func TestRunKeygen(t *testing.T) {
 		capturedResult = result
 	}
 
-	a.runKeygen(context.Background(), mockM, mockK, outputter)
+	if err := a.runKeygen(context.Background(), mockM, mockK, outputter); err != nil {
+		t.Fatal(err)
+	}
 
 	if capturedResult == nil {
 		t.Fatal("Result not captured")
@@ -123,9 +530,329 @@ func TestRunKeygen(t *testing.T) {
 	}
 }
 
+func TestRunKeygenSeeded(t *testing.T) {
+	a := &app{
+		config: config{
+			Threads: 1,
+		},
+		seed: "test",
+	}
+
+	run := func() string {
+		var pub string
+		if err := a.runKeygen(context.Background(), &mockMatcher{match: true},
+			func(r io.Reader) keygen.SSHKey { return ed25519.New(r) },
+			func(_ time.Duration, result keygen.SSHKey) { pub = string(result.SSHPubkey()) },
+		); err != nil {
+			t.Fatal(err)
+		}
+		return pub
+	}
+	first := run()
+	if first == "" {
+		t.Fatal("Result not captured")
+	}
+	if second := run(); second != first {
+		t.Errorf("Seeded runs found different keys: %q and %q", first, second)
+	}
+}
+
+func TestRunKeygenCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	a := &app{
+		config: config{
+			MatchString:        "test",
+			KeyType:            "ed25519",
+			Anchor:             "any",
+			MinWordLength:      4,
+			Threads:            2,
+			Checkpoint:         path,
+			CheckpointInterval: 10 * time.Millisecond,
+		},
+		matchString: "test\nwords",
+	}
+	if err := a.setupCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if a.seed == "" {
+		t.Fatal("Expected a random seed for a checkpointed search")
+	}
+	kg := func(r io.Reader) keygen.SSHKey { return ed25519.New(r) }
+
+	// Interrupt a search that never matches, the progress is saved.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := a.runKeygen(ctx, &mockMatcher{match: false}, kg, func(time.Duration, keygen.SSHKey) {
+		t.Error("Unexpected result")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	state, err := checkpoint.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Seed != a.seed || len(state.Attempts) != 2 || state.Attempts[0] == 0 || state.Elapsed == 0 {
+		t.Fatalf("Unexpected checkpoint %+v", state)
+	}
+
+	// A new search refuses to overwrite the checkpoint.
+	b := &app{config: a.config, matchString: a.matchString}
+	if err := b.setupCheckpoint(); err == nil {
+		t.Error("Expected an error for an existing checkpoint without --resume")
+	}
+
+	// The resumed search continues at the saved attempt.
+	b.config.Resume = true
+	b.config.Threads = 1
+	if err := b.setupCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if b.config.Threads != 2 {
+		t.Errorf("Expected the threads from the checkpoint, got %d", b.config.Threads)
+	}
+	var found string
+	if err := b.runKeygen(context.Background(), &mockMatcher{match: true}, kg, func(_ time.Duration, result keygen.SSHKey) {
+		found = string(result.SSHPubkey())
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{}
+	for i, attempt := range state.Attempts {
+		r := drbg.New([]byte(state.Seed), i)
+		r.SetAttempt(uint64(attempt)) //nolint:gosec // Attempts are never negative.
+		k := ed25519.New(r)
+		k.Generate()
+		want[string(k.SSHPubkey())] = true
+	}
+	if !want[found] {
+		t.Errorf("Resumed search found %q, not a key at a saved attempt", found)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the checkpoint to be removed after a key is found: %v", err)
+	}
+
+	// The search parameters must match the checkpoint.
+	state.Attempts[0]++
+	if err := state.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	position := 1
+	for name, change := range map[string]func(c *app){
+		"name":            func(c *app) { c.config.MatchString = "other" },
+		"match string":    func(c *app) { c.matchString = "other" },
+		"anchor":          func(c *app) { c.config.Anchor = "end" },
+		"position":        func(c *app) { c.config.Position = &position },
+		"min word length": func(c *app) { c.config.MinWordLength = 5 },
+	} {
+		c := &app{config: a.config, matchString: a.matchString}
+		c.config.Resume = true
+		change(c)
+		if err := c.setupCheckpoint(); err == nil {
+			t.Errorf("Expected an error when resuming with another %s", name)
+		}
+	}
+}
+
+func TestRunKeygenCheckpointExponent(t *testing.T) {
+	config := config{
+		MatchString:        "test",
+		KeyType:            "rsa-2048",
+		RSASearch:          "exponent",
+		Anchor:             "any",
+		Threads:            1,
+		Seed:               "seed",
+		Checkpoint:         filepath.Join(t.TempDir(), "state.json"),
+		CheckpointInterval: time.Hour,
+	}
+	kg := func(r io.Reader) keygen.SSHKey { return rsa.NewExponentSearch(r, 2048, rsa.OpenSSH) }
+
+	// An uninterrupted search finds the key checked last of 300.
+	a := &app{config: config, seed: config.Seed}
+	a.config.Checkpoint = ""
+	var checked int
+	var want string
+	if err := a.runKeygen(context.Background(), funcMatcher(func(keygen.SSHKey) bool {
+		checked++
+		return checked == 300
+	}), kg, func(_ time.Duration, result keygen.SSHKey) { want = string(result.SSHPubkey()) }); err != nil {
+		t.Fatal(err)
+	}
+
+	// Interrupted after 100 keys, all with the same modulus.
+	b := &app{config: config, seed: config.Seed}
+	if err := b.setupCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	checked = 0
+	if err := b.runKeygen(ctx, funcMatcher(func(keygen.SSHKey) bool {
+		if checked++; checked == 100 {
+			cancel()
+		}
+		return false
+	}), kg, func(time.Duration, keygen.SSHKey) {
+		t.Error("Unexpected result")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	state, err := checkpoint.Load(config.Checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Attempts[0] < 99 || state.Replay[0] != state.Attempts[0] {
+		t.Fatalf("Expected a replay from attempt 0, got %+v", state)
+	}
+
+	// The resumed search continues with the modulus and exponents.
+	c := &app{config: config, seed: config.Seed}
+	c.config.Resume = true
+	if err := c.setupCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var found string
+	if err := c.runKeygen(ctx, funcMatcher(func(k keygen.SSHKey) bool {
+		return string(k.SSHPubkey()) == want
+	}), kg, func(_ time.Duration, result keygen.SSHKey) { found = string(result.SSHPubkey()) }); err != nil {
+		t.Fatal(err)
+	}
+	if found != want {
+		t.Error("Resumed search did not find the key of the uninterrupted search")
+	}
+}
+
+func TestSetAnchor(t *testing.T) {
+	kg := func(r io.Reader) keygen.SSHKey { return ed25519.New(r) }
+	position := 2
+
+	a := &app{config: config{Anchor: "any", Position: &position}}
+	m := exact.New()
+	if err := m.SetMatchString("AB"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.setAnchor(m, kg); err != nil {
+		t.Fatal(err)
+	}
+	// --position anchors at the start.
+	if !m.Match(&mockKey{pub: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIxxAB\n")}) {
+		t.Error("Expected a match 2 characters after the start")
+	}
+	if m.Match(&mockKey{pub: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIxAB\n")}) {
+		t.Error("Expected no match 1 character after the start")
+	}
+
+	a = &app{config: config{Anchor: "start"}}
+	if err := a.setAnchor(&mockMatcher{}, kg); err == nil {
+		t.Error("Expected an error for a matcher without anchors")
+	}
+	a = &app{config: config{Anchor: "any"}}
+	if err := a.setAnchor(&mockMatcher{}, kg); err != nil {
+		t.Errorf("Expected no error without anchors, got %v", err)
+	}
+}
+
 type mockMatcher struct {
 	match bool
 }
 
-func (m *mockMatcher) SetMatchString(s string)    {}
-func (m *mockMatcher) Match(k keygen.SSHKey) bool { return m.match }
+func (m *mockMatcher) SetMatchString(s string) error { return nil }
+func (m *mockMatcher) Match(k keygen.SSHKey) bool    { return m.match }
+
+type funcMatcher func(keygen.SSHKey) bool
+
+func (m funcMatcher) SetMatchString(string) error { return nil }
+func (m funcMatcher) Match(k keygen.SSHKey) bool  { return m(k) }
+
+func TestRunKeygenEach(t *testing.T) {
+	tmpDir := t.TempDir()
+	m := multi.New()
+	if err := m.SetMatchString("alice\nb/ob"); err != nil {
+		t.Fatal(err)
+	}
+	a := &app{
+		config: config{
+			MatchString: "team",
+			OutputDir:   tmpDir,
+			Threads:     2,
+			Each:        true,
+		},
+		locator: m,
+		remover: m,
+	}
+
+	// Every worker keeps finding keys for both patterns, each is output once.
+	var i atomic.Int64
+	kg := func(io.Reader) keygen.SSHKey {
+		if i.Add(1)%2 == 0 {
+			return &mockKey{pub: []byte("ssh-ed25519 AAAAalice\n"), priv: []byte("alice-priv")}
+		}
+		return &mockKey{pub: []byte("ssh-ed25519 AAAAb/ob\n"), priv: []byte("b/ob-priv")}
+	}
+	if err := a.runKeygen(context.Background(), m, kg, a.outputPEM); err != nil {
+		t.Fatal(err)
+	}
+
+	// The '/' of a pattern is escaped in its file name.
+	for pattern, name := range map[string]string{"alice": "alice", "b/ob": "b_ob"} {
+		//nolint:gosec // G304: Path is controlled by the test via t.TempDir()
+		content, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != pattern+"-priv" {
+			t.Errorf("Unexpected private key for %s: %s", pattern, content)
+		}
+	}
+	if len(m.Patterns()) != 0 {
+		t.Errorf("Expected no remaining patterns, got %v", m.Patterns())
+	}
+}
+
+func TestRunKeygenDeadline(t *testing.T) {
+	a := &app{
+		config: config{
+			Threads:  1,
+			Deadline: 20 * time.Millisecond,
+		},
+	}
+	kg := func(io.Reader) keygen.SSHKey { return &mockKey{} }
+
+	start := time.Now()
+	if err := a.runKeygen(context.Background(), &mockMatcher{match: false}, kg, func(time.Duration, keygen.SSHKey) {
+		t.Error("Unexpected result")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the search to stop at the deadline, took %s", elapsed)
+	}
+}
+
+// hopelessMatcher never matches, and says so.
+type hopelessMatcher struct {
+	mockMatcher
+}
+
+func (m *hopelessMatcher) Difficulty() float64 { return 1e30 }
+
+func TestRunKeygenMaxETA(t *testing.T) {
+	warmup := etaWarmup
+	etaWarmup = 10 * time.Millisecond
+	defer func() { etaWarmup = warmup }()
+	kg := func(io.Reader) keygen.SSHKey { return &mockKey{} }
+	unexpected := func(time.Duration, keygen.SSHKey) { t.Error("Unexpected result") }
+
+	a := &app{config: config{Threads: 1, MaxETA: time.Hour}}
+	err := a.runKeygen(context.Background(), &hopelessMatcher{}, kg, unexpected)
+	if !errors.Is(err, errTooSlow) {
+		t.Errorf("Expected the search to be refused, got %v", err)
+	}
+
+	// --force searches until the deadline.
+	a.config.Force, a.config.Deadline = true, 50*time.Millisecond
+	if err := a.runKeygen(context.Background(), &hopelessMatcher{}, kg, unexpected); err != nil {
+		t.Errorf("Expected no error with --force, got %v", err)
+	}
+}
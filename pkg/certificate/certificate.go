@@ -0,0 +1,186 @@
+package certificate
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultUserExtensions are the extensions ssh-keygen adds to user
+// certificates when none are given.
+var DefaultUserExtensions = map[string]string{
+	"permit-X11-forwarding":   "",
+	"permit-agent-forwarding": "",
+	"permit-port-forwarding":  "",
+	"permit-pty":              "",
+	"permit-user-rc":          "",
+}
+
+// Options describe the certificate issued by Sign.
+type Options struct {
+	KeyID      string
+	Principals []string
+	// Host issues a host certificate instead of a user certificate.
+	Host   bool
+	Serial uint64
+	// ValidAfter and ValidBefore are unix timestamps, see ParseValidity.
+	ValidAfter      uint64
+	ValidBefore     uint64
+	CriticalOptions map[string]string
+	// Extensions default to DefaultUserExtensions for user certificates if
+	// nil. Set an empty map to issue a certificate without extensions.
+	Extensions map[string]string
+}
+
+// Sign issues a certificate for key, signed by the certificate authority ca.
+func Sign(key ssh.PublicKey, ca ssh.Signer, opts Options) (*ssh.Certificate, error) {
+	certType := uint32(ssh.UserCert)
+	extensions := opts.Extensions
+	if opts.Host {
+		certType = ssh.HostCert
+	} else if extensions == nil {
+		extensions = maps.Clone(DefaultUserExtensions)
+	}
+	if opts.ValidBefore <= opts.ValidAfter {
+		return nil, errors.New("certificate: empty validity interval")
+	}
+
+	cert := &ssh.Certificate{
+		Key:             key,
+		Serial:          opts.Serial,
+		CertType:        certType,
+		KeyId:           opts.KeyID,
+		ValidPrincipals: opts.Principals,
+		ValidAfter:      opts.ValidAfter,
+		ValidBefore:     opts.ValidBefore,
+		Permissions: ssh.Permissions{
+			CriticalOptions: opts.CriticalOptions,
+			Extensions:      extensions,
+		},
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// ParseOptions parses "name" or "name=value" pairs into a map, as used for
+// critical options and extensions.
+func ParseOptions(options []string) (map[string]string, error) {
+	if options == nil {
+		return nil, nil
+	}
+	m := make(map[string]string, len(options))
+	for _, o := range options {
+		name, value, _ := strings.Cut(o, "=")
+		if name == "" {
+			return nil, fmt.Errorf("certificate: invalid option %q", o)
+		}
+		m[name] = value
+	}
+	return m, nil
+}
+
+// ParseValidity parses a validity interval in the format of ssh-keygen -V:
+// "from:to" or just "to", in which case the certificate is valid from now.
+// Times are either relative to now, like "-5m" or "+52w", absolute dates as
+// YYYYMMDD, YYYYMMDDHHMM or YYYYMMDDHHMMSS in UTC, or "always" and "forever"
+// for an unbounded interval.
+func ParseValidity(s string, now time.Time) (uint64, uint64, error) {
+	from, to, ok := strings.Cut(s, ":")
+	if !ok {
+		from, to = "+0s", from
+	}
+	validAfter, err := parseTime(from, now, "always")
+	if err != nil {
+		return 0, 0, err
+	}
+	validBefore, err := parseTime(to, now, "forever")
+	if err != nil {
+		return 0, 0, err
+	}
+	if validBefore <= validAfter {
+		return 0, 0, fmt.Errorf("certificate: empty validity interval %q", s)
+	}
+	return validAfter, validBefore, nil
+}
+
+func parseTime(s string, now time.Time, unbounded string) (uint64, error) {
+	switch {
+	case s == unbounded && unbounded == "always":
+		return 0, nil
+	case s == unbounded:
+		return ssh.CertTimeInfinity, nil
+	case strings.HasPrefix(s, "+"), strings.HasPrefix(s, "-"):
+		d, err := parseDuration(s[1:])
+		if err != nil {
+			return 0, err
+		}
+		if s[0] == '-' {
+			d = -d
+		}
+		return unix(now.Add(d)), nil
+	}
+
+	for _, layout := range []string{"20060102", "200601021504", "20060102150405"} {
+		if len(s) != len(layout) {
+			continue
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return 0, fmt.Errorf("certificate: invalid time %q: %w", s, err)
+		}
+		return unix(t), nil
+	}
+	return 0, fmt.Errorf("certificate: invalid time %q", s)
+}
+
+// parseDuration parses durations like "1w2d" with the units ssh-keygen
+// accepts: s, m, h, d and w. A number without unit is in seconds.
+func parseDuration(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{
+		's': time.Second,
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+	if s == "" {
+		return 0, errors.New("certificate: empty duration")
+	}
+	var total time.Duration
+	for s != "" {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		n, err := strconv.ParseInt(s[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("certificate: invalid duration %q", s)
+		}
+		unit := time.Second
+		if i < len(s) {
+			var ok bool
+			if unit, ok = units[s[i]]; !ok {
+				return 0, fmt.Errorf("certificate: invalid duration unit %q", s[i])
+			}
+			i++
+		}
+		total += time.Duration(n) * unit
+		s = s[i:]
+	}
+	return total, nil
+}
+
+func unix(t time.Time) uint64 {
+	if t.Unix() < 0 {
+		return 0
+	}
+	return uint64(t.Unix())
+}
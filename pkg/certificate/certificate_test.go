@@ -0,0 +1,125 @@
+package certificate
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSign(t *testing.T) {
+	_, caPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ssh.NewSignerFromKey(caPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	cert, err := Sign(key, ca, Options{
+		KeyID:           "alice",
+		Principals:      []string{"alice", "root"},
+		Serial:          42,
+		ValidAfter:      uint64(now.Add(-time.Minute).Unix()),
+		ValidBefore:     uint64(now.Add(time.Hour).Unix()),
+		CriticalOptions: map[string]string{"force-command": "/bin/true"},
+	})
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if cert.Extensions["permit-pty"] != "" || len(cert.Extensions) != len(DefaultUserExtensions) {
+		t.Errorf("Expected default user extensions, got %v", cert.Extensions)
+	}
+
+	checker := ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(ca.PublicKey().Marshal())
+		},
+		SupportedCriticalOptions: []string{"force-command"},
+	}
+	if err := checker.CheckCert("root", cert); err != nil {
+		t.Errorf("Certificate does not validate: %v", err)
+	}
+	if err := checker.CheckCert("bob", cert); err == nil {
+		t.Error("Expected certificate to be invalid for another principal")
+	}
+
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey(ssh.MarshalAuthorizedKey(cert))
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	if parsed.(*ssh.Certificate).KeyId != "alice" {
+		t.Error("Unexpected key id")
+	}
+
+	host, err := Sign(key, ca, Options{KeyID: "host", Host: true, ValidBefore: ssh.CertTimeInfinity})
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if host.CertType != ssh.HostCert || len(host.Extensions) != 0 {
+		t.Errorf("Unexpected host certificate: %+v", host)
+	}
+}
+
+func TestParseValidity(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	testCases := []struct {
+		in            string
+		after, before uint64
+		err           bool
+	}{
+		{"always:forever", 0, ssh.CertTimeInfinity, false},
+		{"+52w", uint64(now.Unix()), uint64(now.Add(52 * 7 * 24 * time.Hour).Unix()), false},
+		{"-5m:+1h30m", uint64(now.Add(-5 * time.Minute).Unix()), uint64(now.Add(90 * time.Minute).Unix()), false},
+		{"20240101:20250101", 1704067200, 1735689600, false},
+		{"202401010000:forever", 1704067200, ssh.CertTimeInfinity, false},
+		{"forever:always", 0, 0, true},
+		{"+1h:-1h", 0, 0, true},
+		{"+1y", 0, 0, true},
+		{"tomorrow", 0, 0, true},
+	}
+	for _, tc := range testCases {
+		after, before, err := ParseValidity(tc.in, now)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.in, err)
+			continue
+		}
+		if after != tc.after || before != tc.before {
+			t.Errorf("%s: expected %d:%d, got %d:%d", tc.in, tc.after, tc.before, after, before)
+		}
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	m, err := ParseOptions([]string{"permit-pty", "source-address=10.0.0.0/8,192.168.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := m["permit-pty"]; !ok || v != "" {
+		t.Errorf("Unexpected permit-pty: %q", v)
+	}
+	if m["source-address"] != "10.0.0.0/8,192.168.0.1" {
+		t.Errorf("Unexpected source-address: %q", m["source-address"])
+	}
+	if _, err := ParseOptions([]string{"=x"}); err == nil {
+		t.Error("Expected error for empty option name")
+	}
+}
@@ -0,0 +1,97 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Search identifies what is searched for. A search can only be resumed with
+// the same parameters, otherwise the saved progress means nothing.
+type Search struct {
+	MatchString string `json:"match_string"`
+	// MatchHash is the SHA-256 of the match string the matcher got, which
+	// can be read from --match-file or --pattern.
+	MatchHash     string `json:"match_sha256"`
+	Matcher       string `json:"matcher"`
+	Expression    string `json:"expression,omitempty"`
+	KeyType       string `json:"key_type"`
+	RSASearch     string `json:"rsa_search,omitempty"`
+	Anchor        string `json:"anchor"`
+	Position      int    `json:"position,omitempty"`
+	MinWordLength int    `json:"min_word_length"`
+}
+
+// State is the progress of a seeded search. Workers generate their keys from
+// the seed and the attempt number, so a search resumed at the saved attempts
+// does not repeat any keys.
+//
+// Anyone who has the state can reproduce the keys, so it is written with the
+// same permissions as a private key.
+type State struct {
+	Search
+	Seed string `json:"seed"`
+	// Attempts is the number of attempts every worker has completed.
+	Attempts []int64 `json:"attempts"`
+	// Replay is the number of attempts before Attempts every worker generates
+	// again without matching, to continue keys that derive their candidates
+	// from an earlier attempt's stream, like the RSA exponent search.
+	Replay []int64 `json:"replay,omitempty"`
+	// Elapsed is the total search time of all runs.
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// Load reads a state saved with Save.
+func Load(path string) (*State, error) {
+	b, err := os.ReadFile(path) //nolint:gosec // The path is given by the user.
+	if err != nil {
+		return nil, err
+	}
+	var s State
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("checkpoint: could not parse %s: %w", path, err)
+	}
+	if s.Seed == "" || len(s.Attempts) == 0 {
+		return nil, fmt.Errorf("checkpoint: %s has no seed or workers", path)
+	}
+	if len(s.Replay) != 0 && len(s.Replay) != len(s.Attempts) {
+		return nil, fmt.Errorf("checkpoint: %s has %d replays for %d workers", path, len(s.Replay), len(s.Attempts))
+	}
+	return &s, nil
+}
+
+// Check returns an error if the state was saved for another search.
+func (s *State) Check(search Search) error {
+	if s.Search != search {
+		return fmt.Errorf("checkpoint: saved for %+v, not %+v", s.Search, search)
+	}
+	return nil
+}
+
+// Save writes the state to path. It is written to a temporary file first and
+// renamed, so a crash while saving keeps the previous state.
+func (s *State) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", " ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) //nolint:errcheck // Fails once renamed.
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
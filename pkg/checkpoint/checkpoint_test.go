@@ -0,0 +1,72 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	search := Search{MatchString: "abc", Matcher: "ignorecase", KeyType: "ed25519"}
+	s := &State{
+		Search:   search,
+		Seed:     "seed",
+		Attempts: []int64{10, 20},
+		Elapsed:  time.Minute,
+	}
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// Saving again replaces the state.
+	s.Attempts = []int64{11, 21}
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, s) {
+		t.Errorf("Loaded %+v, want %+v", loaded, s)
+	}
+
+	if err := loaded.Check(search); err != nil {
+		t.Errorf("Check failed for the same search: %v", err)
+	}
+	search.MatchString = "abd"
+	if err := loaded.Check(search); err == nil {
+		t.Error("Expected Check to fail for another search")
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the state file, got %v", entries)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"seed": ""}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Expected an error for a state without seed")
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,46 @@
+package drbg
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+// Reader is a deterministic random bit generator based on ChaCha20. Every
+// worker gets its own key derived from the seed, and every attempt its own
+// stream, so a key can be reproduced from the seed, worker and attempt alone.
+//
+// The output is only as unpredictable as the seed, keys generated from it are
+// meant for tests and audits and not for production use.
+type Reader struct {
+	key    [chacha20.KeySize]byte
+	cipher *chacha20.Cipher
+}
+
+// New returns the reader of a worker, positioned at attempt 0.
+func New(seed []byte, worker int) *Reader {
+	h := sha256.New()
+	h.Write([]byte("vanity-ssh-keygen drbg v1"))
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(worker))) //nolint:gosec // Worker indexes are never negative.
+	h.Write(seed)
+	r := &Reader{}
+	h.Sum(r.key[:0])
+	r.SetAttempt(0)
+	return r
+}
+
+// SetAttempt restarts the output at the beginning of the stream for attempt.
+func (r *Reader) SetAttempt(attempt uint64) {
+	var nonce [chacha20.NonceSize]byte
+	binary.BigEndian.PutUint64(nonce[chacha20.NonceSize-8:], attempt)
+	// Only fails for invalid key and nonce sizes.
+	r.cipher, _ = chacha20.NewUnauthenticatedCipher(r.key[:], nonce[:])
+}
+
+// Read fills p with the next bytes of the current attempt's stream.
+func (r *Reader) Read(p []byte) (int, error) {
+	clear(p)
+	r.cipher.XORKeyStream(p, p)
+	return len(p), nil
+}
@@ -0,0 +1,48 @@
+package drbg
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func read(t *testing.T, r io.Reader, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestReader(t *testing.T) {
+	seed := []byte("seed")
+	r := New(seed, 0)
+	first := read(t, r, 64)
+	if bytes.Equal(first, make([]byte, 64)) {
+		t.Fatal("Reader returned zeros")
+	}
+	if next := read(t, r, 64); bytes.Equal(first, next) {
+		t.Error("Reader repeated its output within an attempt")
+	}
+
+	// The same seed, worker and attempt reproduce the same stream.
+	r.SetAttempt(0)
+	if !bytes.Equal(read(t, r, 64), first) {
+		t.Error("SetAttempt(0) did not restart the stream")
+	}
+	if !bytes.Equal(read(t, New(seed, 0), 64), first) {
+		t.Error("A new reader with the same seed returned a different stream")
+	}
+
+	r.SetAttempt(1)
+	if bytes.Equal(read(t, r, 64), first) {
+		t.Error("Different attempts returned the same stream")
+	}
+	if bytes.Equal(read(t, New(seed, 1), 64), first) {
+		t.Error("Different workers returned the same stream")
+	}
+	if bytes.Equal(read(t, New([]byte("other"), 0), 64), first) {
+		t.Error("Different seeds returned the same stream")
+	}
+}
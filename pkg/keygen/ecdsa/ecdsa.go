@@ -0,0 +1,148 @@
+package ecdsa
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519/edkey"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+type localEcdsa struct {
+	rand       io.Reader
+	curve      elliptic.Curve
+	scalar     []byte
+	privateKey *ecdsa.PrivateKey
+	keyType    string
+	// bin is the binary public key blob, the fixed header is filled in by New.
+	bin       []byte
+	headerLen int
+	pubKeyBuf []byte
+	// encoded is set once pubKeyBuf holds the base64 encoding of bin, it is
+	// only encoded when it is used.
+	encoded bool
+}
+
+func New(rand io.Reader, curve elliptic.Curve) *localEcdsa {
+	var keyType, curveID string
+	switch curve {
+	case elliptic.P256():
+		keyType, curveID = ssh.KeyAlgoECDSA256, "nistp256"
+	case elliptic.P384():
+		keyType, curveID = ssh.KeyAlgoECDSA384, "nistp384"
+	case elliptic.P521():
+		keyType, curveID = ssh.KeyAlgoECDSA521, "nistp521"
+	default:
+		panic("ecdsa: unsupported curve " + curve.Params().Name)
+	}
+	// Uncompressed point: 0x04 || X || Y
+	pointLen := 1 + 2*((curve.Params().BitSize+7)/8)
+
+	bin := make([]byte, 0, 4+len(keyType)+4+len(curveID)+4+pointLen)
+	bin = binary.BigEndian.AppendUint32(bin, uint32(len(keyType)))
+	bin = append(bin, keyType...)
+	bin = binary.BigEndian.AppendUint32(bin, uint32(len(curveID)))
+	bin = append(bin, curveID...)
+	bin = binary.BigEndian.AppendUint32(bin, uint32(pointLen))
+	headerLen := len(bin)
+	bin = bin[:cap(bin)]
+
+	// "<keytype> <base64>\n"
+	pubKeyBuf := make([]byte, len(keyType)+1+base64.StdEncoding.EncodedLen(len(bin))+1)
+	copy(pubKeyBuf, keyType+" ")
+	pubKeyBuf[len(pubKeyBuf)-1] = '\n'
+
+	return &localEcdsa{
+		rand:      rand,
+		curve:     curve,
+		scalar:    make([]byte, (curve.Params().BitSize+7)/8),
+		keyType:   keyType,
+		bin:       bin,
+		headerLen: headerLen,
+		pubKeyBuf: pubKeyBuf,
+	}
+}
+
+func (s *localEcdsa) Generate() {
+	// ecdsa.GenerateKey ignores its reader, so sample the scalar here to
+	// make keys reproducible from a deterministic reader. Scalars out of
+	// range are rejected and drawn again. Without entropy there is no new
+	// key, so Generate panics.
+	excessBits := len(s.scalar)*8 - s.curve.Params().BitSize
+	for {
+		if _, err := io.ReadFull(s.rand, s.scalar); err != nil {
+			panic("ecdsa: could not read entropy: " + err.Error())
+		}
+		s.scalar[0] &= 0xff >> excessBits
+		privateKey, err := ecdsa.ParseRawPrivateKey(s.curve, s.scalar)
+		if err == nil {
+			s.privateKey = privateKey
+			break
+		}
+	}
+	point, _ := s.privateKey.PublicKey.Bytes()
+	copy(s.bin[s.headerLen:], point)
+	s.encoded = false
+}
+
+func (s *localEcdsa) updatePubkey() {
+	base64.StdEncoding.Encode(s.pubKeyBuf[len(s.keyType)+1:len(s.pubKeyBuf)-1], s.bin)
+	s.encoded = true
+}
+
+// Blob returns the binary public key.
+func (s *localEcdsa) Blob() []byte {
+	return s.bin
+}
+
+// Layout returns the layout of the public key line. The point is uncompressed,
+// its X and Y coordinates depend on the key except for the bits above the
+// curve size.
+func (s *localEcdsa) Layout() layout.Layout {
+	bin := make([]byte, len(s.bin))
+	copy(bin, s.bin[:s.headerLen])
+	bin[s.headerLen] = 4
+	free := make([]byte, len(s.bin))
+	coordinateLen := len(s.scalar)
+	excessBits := coordinateLen*8 - s.curve.Params().BitSize
+	for _, start := range []int{s.headerLen + 1, s.headerLen + 1 + coordinateLen} {
+		for i := range coordinateLen {
+			free[start+i] = 0xff
+		}
+		free[start] >>= excessBits
+	}
+	return layout.New(s.keyType, bin, free)
+}
+
+func (s *localEcdsa) SSHPubkey() []byte {
+	if !s.encoded {
+		s.updatePubkey()
+	}
+	return s.pubKeyBuf
+}
+
+func (s *localEcdsa) PrivateKey() crypto.Signer {
+	return s.privateKey
+}
+
+func (s *localEcdsa) SSHPrivkey() []byte {
+	privDER, err := edkey.MarshalPrivateKey(s.privateKey, edkey.Options{})
+	if err != nil {
+		return nil
+	}
+	b := pem.Block{
+		Type:    "OPENSSH PRIVATE KEY",
+		Headers: nil,
+		Bytes:   privDER,
+	}
+	// Private key in PEM format
+	privatePEM := pem.EncodeToMemory(&b)
+	return privatePEM
+}
@@ -0,0 +1,72 @@
+package ecdsa
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"testing/iotest"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestECDSA(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		t.Run(curve.Params().Name, func(t *testing.T) {
+			e := New(rand.Reader, curve)
+			e.Generate()
+
+			pub := e.SSHPubkey()
+			if len(pub) == 0 {
+				t.Error("SSHPubkey() returned empty result")
+			}
+
+			parsedPub, _, _, _, err := ssh.ParseAuthorizedKey(pub)
+			if err != nil {
+				t.Fatalf("Failed to parse authorized key: %v", err)
+			}
+			if parsedPub.Type() != e.keyType {
+				t.Errorf("Expected key type %s, got %s", e.keyType, parsedPub.Type())
+			}
+
+			priv := e.SSHPrivkey()
+			if len(priv) == 0 {
+				t.Error("SSHPrivkey() returned empty result")
+			}
+
+			signer, err := ssh.ParsePrivateKey(priv)
+			if err != nil {
+				t.Fatalf("Failed to parse private key: %v", err)
+			}
+			if !bytes.Equal(signer.PublicKey().Marshal(), parsedPub.Marshal()) {
+				t.Error("Private key does not match public key")
+			}
+		})
+	}
+}
+
+func TestGenerateReadError(t *testing.T) {
+	e := New(iotest.ErrReader(errors.New("no entropy")), elliptic.P256())
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Generate to panic without entropy")
+		}
+	}()
+	e.Generate()
+}
+
+func BenchmarkSSHPubkey(b *testing.B) {
+	e := New(rand.Reader, elliptic.P256())
+	e.Generate()
+	for i := 0; i < b.N; i++ {
+		_ = e.SSHPubkey()
+	}
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	e := New(rand.Reader, elliptic.P256())
+	for i := 0; i < b.N; i++ {
+		e.Generate()
+	}
+}
@@ -1,45 +1,76 @@
 package ed25519
 
 import (
+	"crypto"
 	"crypto/ed25519"
-	"crypto/rand"
 	"encoding/base64"
 	"encoding/pem"
+	"io"
 
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519/edkey"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
 )
 
 var ed25519BinaryHeader = []byte{0, 0, 0, 11, 's', 's', 'h', '-', 'e', 'd', '2', '5', '5', '1', '9', 0, 0, 0, 32}
 
 type ed struct {
+	rand       io.Reader
 	publicKey  ed25519.PublicKey
 	privateKey ed25519.PrivateKey
+	blob       [51]byte
 	pubKeyBuf  [81]byte
+	// encoded is set once pubKeyBuf holds the base64 encoding of blob, it
+	// is only encoded when it is used.
+	encoded bool
 }
 
-func New() *ed {
-	return &ed{}
+func New(rand io.Reader) *ed {
+	return &ed{rand: rand}
 }
 
 func (s *ed) Generate() {
-	s.publicKey, s.privateKey, _ = ed25519.GenerateKey(rand.Reader)
-	s.updatePubkey()
+	// The key is derived from a seed read from s.rand, so a deterministic
+	// reader gives a reproducible key.
+	var seed [ed25519.SeedSize]byte
+	if _, err := io.ReadFull(s.rand, seed[:]); err != nil {
+		panic("ed25519: could not read entropy: " + err.Error())
+	}
+	s.privateKey = ed25519.NewKeyFromSeed(seed[:])
+	s.publicKey = ed25519.PublicKey(s.privateKey[ed25519.SeedSize:])
+	copy(s.blob[0:19], ed25519BinaryHeader)
+	copy(s.blob[19:51], s.publicKey)
+	s.encoded = false
 }
 
 func (s *ed) updatePubkey() {
-	var bin [51]byte
-	copy(bin[0:19], ed25519BinaryHeader)
-	copy(bin[19:51], s.publicKey)
-
 	copy(s.pubKeyBuf[0:12], "ssh-ed25519 ")
-	base64.StdEncoding.Encode(s.pubKeyBuf[12:80], bin[:])
+	base64.StdEncoding.Encode(s.pubKeyBuf[12:80], s.blob[:])
 	s.pubKeyBuf[80] = '\n'
+	s.encoded = true
+}
+
+// Blob returns the binary public key.
+func (s *ed) Blob() []byte {
+	return s.blob[:]
+}
+
+// Layout returns the layout of the public key line, every bit of the 32 byte
+// public key depends on the key.
+func (s *ed) Layout() layout.Layout {
+	return layout.Ed25519()
 }
 
 func (s *ed) SSHPubkey() []byte {
+	if !s.encoded {
+		s.updatePubkey()
+	}
 	return s.pubKeyBuf[:]
 }
 
+func (s *ed) PrivateKey() crypto.Signer {
+	return s.privateKey
+}
+
 func (s *ed) SSHPrivkey() []byte {
 	privDER := edkey.MarshalED25519PrivateKey(s.privateKey)
 	b := pem.Block{
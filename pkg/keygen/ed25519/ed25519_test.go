@@ -1,13 +1,16 @@
 package ed25519
 
 import (
+	"crypto/rand"
+	"errors"
 	"testing"
+	"testing/iotest"
 
 	"golang.org/x/crypto/ssh"
 )
 
 func TestEd25519(t *testing.T) {
-	e := New()
+	e := New(rand.Reader)
 	e.Generate()
 
 	pub := e.SSHPubkey()
@@ -31,8 +34,18 @@ func TestEd25519(t *testing.T) {
 	}
 }
 
+func TestGenerateReadError(t *testing.T) {
+	e := New(iotest.ErrReader(errors.New("no entropy")))
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Generate to panic without entropy")
+		}
+	}()
+	e.Generate()
+}
+
 func BenchmarkSSHPubkey(b *testing.B) {
-	e := New()
+	e := New(rand.Reader)
 	e.Generate()
 	for i := 0; i < b.N; i++ {
 		_ = e.SSHPubkey()
@@ -40,7 +53,7 @@ func BenchmarkSSHPubkey(b *testing.B) {
 }
 
 func BenchmarkGenerate(b *testing.B) {
-	e := New()
+	e := New(rand.Reader)
 	for i := 0; i < b.N; i++ {
 		e.Generate()
 	}
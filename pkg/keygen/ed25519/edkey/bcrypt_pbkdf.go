@@ -0,0 +1,82 @@
+package edkey
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+
+	"golang.org/x/crypto/blowfish"
+)
+
+// bcrypt_pbkdf(3) from OpenBSD, the KDF OpenSSH uses for passphrase protected
+// private keys. See https://flak.tedunangst.com/post/bcrypt-pbkdf
+
+const bcryptBlockSize = 32
+
+var bcryptMagic = []byte("OxychromaticBlowfishSwatDynamite")
+
+func bcryptPBKDF(password, salt []byte, rounds, keyLen int) ([]byte, error) {
+	if rounds < 1 {
+		return nil, errors.New("bcrypt_pbkdf: number of rounds is too small")
+	}
+	if len(password) == 0 {
+		return nil, errors.New("bcrypt_pbkdf: empty password")
+	}
+	if len(salt) == 0 || len(salt) > 1<<20 {
+		return nil, errors.New("bcrypt_pbkdf: bad salt length")
+	}
+	if keyLen > 1024 {
+		return nil, errors.New("bcrypt_pbkdf: key length is too large")
+	}
+
+	numBlocks := (keyLen + bcryptBlockSize - 1) / bcryptBlockSize
+	key := make([]byte, numBlocks*bcryptBlockSize)
+
+	shaPass := sha512.Sum512(password)
+	h := sha512.New()
+	var shaSalt [sha512.Size]byte
+	var tmp, out [bcryptBlockSize]byte
+	for block := 1; block <= numBlocks; block++ {
+		h.Reset()
+		h.Write(salt)
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(block)))
+		bcryptHash(tmp[:], shaPass[:], h.Sum(shaSalt[:0]))
+		out = tmp
+
+		for range rounds - 1 {
+			shaSalt = sha512.Sum512(tmp[:])
+			bcryptHash(tmp[:], shaPass[:], shaSalt[:])
+			for j := range out {
+				out[j] ^= tmp[j]
+			}
+		}
+
+		// The output is spread over the key so that a short key still
+		// depends on every block.
+		for i, v := range out {
+			key[i*numBlocks+(block-1)] = v
+		}
+	}
+	return key[:keyLen], nil
+}
+
+func bcryptHash(out, shaPass, shaSalt []byte) {
+	c, err := blowfish.NewSaltedCipher(shaPass, shaSalt)
+	if err != nil {
+		panic(err)
+	}
+	for range 64 {
+		blowfish.ExpandKey(shaSalt, c)
+		blowfish.ExpandKey(shaPass, c)
+	}
+	copy(out, bcryptMagic)
+	for i := 0; i < bcryptBlockSize; i += 8 {
+		for range 64 {
+			c.Encrypt(out[i:i+8], out[i:i+8])
+		}
+	}
+	// Blowfish works on big-endian words, bcrypt_pbkdf outputs little-endian.
+	for i := 0; i < bcryptBlockSize; i += 4 {
+		out[i], out[i+1], out[i+2], out[i+3] = out[i+3], out[i+2], out[i+1], out[i]
+	}
+}
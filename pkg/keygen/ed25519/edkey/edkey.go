@@ -1,12 +1,37 @@
 package edkey
 
 import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
 	"math/rand"
 
 	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/ssh"
 )
 
+// DefaultRounds is the bcrypt KDF cost used by ssh-keygen.
+const DefaultRounds = 16
+
+// Options control how MarshalPrivateKey writes a private key.
+type Options struct {
+	// Passphrase encrypts the private key with aes256-ctr, using a key
+	// derived with the bcrypt KDF. The key is written unencrypted if empty.
+	Passphrase []byte
+	// Rounds is the bcrypt KDF cost, DefaultRounds if zero.
+	Rounds int
+	// Comment is stored with the private key, ssh-keygen shows it when
+	// deriving the public key.
+	Comment string
+}
+
 /*
 	Writes ed25519 private keys into the new OpenSSH private key format.
 
@@ -14,6 +39,16 @@ I have no idea why this isn't implemented anywhere yet, you can do seemingly
 everything except write it to disk in the OpenSSH private key format.
 */
 func MarshalED25519PrivateKey(key ed25519.PrivateKey) []byte {
+	b, err := MarshalPrivateKey(key, Options{})
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// MarshalPrivateKey writes ed25519, ecdsa and rsa private keys into the
+// OpenSSH private key format, optionally encrypted with a passphrase.
+func MarshalPrivateKey(key crypto.PrivateKey, opts Options) ([]byte, error) {
 	// Add our key header (followed by a null byte)
 	magic := append([]byte("openssh-key-v1"), 0)
 
@@ -31,10 +66,7 @@ func MarshalED25519PrivateKey(key ed25519.PrivateKey) []byte {
 		Check1  uint32
 		Check2  uint32
 		Keytype string
-		Pub     []byte
-		Priv    []byte
-		Comment string
-		Pad     []byte `ssh:"rest"`
+		Rest    []byte `ssh:"rest"`
 	}{}
 
 	// Set our check ints
@@ -42,50 +74,138 @@ func MarshalED25519PrivateKey(key ed25519.PrivateKey) []byte {
 	pk1.Check1 = ci
 	pk1.Check2 = ci
 
-	// Set our key type
-	pk1.Keytype = ssh.KeyAlgoED25519
-
-	// Add the pubkey to the optionally-encrypted block
-	pk, ok := key.Public().(ed25519.PublicKey)
+	signer, ok := key.(crypto.Signer)
 	if !ok {
-		// fmt.Fprintln(os.Stderr, "ed25519.PublicKey type assertion failed on an ed25519 public key. This should never ever happen.")
-		return nil
+		return nil, fmt.Errorf("edkey: unsupported key type %T", key)
+	}
+	pubKey, err := ssh.NewPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
 	}
-	pubKey := []byte(pk)
-	pk1.Pub = pubKey
 
-	// Add our private key
-	pk1.Priv = []byte(key)
+	// Set our key type
+	pk1.Keytype = pubKey.Type()
+
+	// Add the key type specific fields to the optionally-encrypted block
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		pk1.Rest = ssh.Marshal(struct {
+			Pub  []byte
+			Priv []byte
+		}{
+			Pub:  []byte(k.Public().(ed25519.PublicKey)),
+			Priv: []byte(k),
+		})
+	case *ecdsa.PrivateKey:
+		point, err := k.PublicKey.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		pk1.Rest = ssh.Marshal(struct {
+			Curve string
+			Pub   []byte
+			D     *big.Int
+		}{
+			// The curve identifier is the part after "ecdsa-sha2-"
+			Curve: pk1.Keytype[len("ecdsa-sha2-"):],
+			Pub:   point,
+			D:     k.D,
+		})
+	case *rsa.PrivateKey:
+		if len(k.Primes) != 2 {
+			return nil, errors.New("edkey: multi-prime rsa keys are not supported")
+		}
+		// OpenSSH only needs the CRT coefficient, the other values are
+		// derived when the key is loaded.
+		iqmp := k.Precomputed.Qinv
+		if iqmp == nil {
+			iqmp = new(big.Int).ModInverse(k.Primes[1], k.Primes[0])
+		}
+		pk1.Rest = ssh.Marshal(struct {
+			N    *big.Int
+			E    *big.Int
+			D    *big.Int
+			Iqmp *big.Int
+			P    *big.Int
+			Q    *big.Int
+		}{
+			N:    k.N,
+			E:    big.NewInt(int64(k.E)),
+			D:    k.D,
+			Iqmp: iqmp,
+			P:    k.Primes[0],
+			Q:    k.Primes[1],
+		})
+	default:
+		return nil, fmt.Errorf("edkey: unsupported key type %T", key)
+	}
 
-	// Might be useful to put something in here at some point
-	pk1.Comment = ""
+	pk1.Rest = append(pk1.Rest, ssh.Marshal(struct{ Comment string }{opts.Comment})...)
 
-	// Add some padding to match the encryption block size within PrivKeyBlock (without Pad field)
+	w.CipherName = "none"
+	w.KdfName = "none"
+	w.KdfOpts = ""
 	// 8 doesn't match the documentation, but that's what ssh-keygen uses for unencrypted keys. *shrug*
 	bs := 8
-	blockLen := len(ssh.Marshal(pk1))
-	padLen := (bs - (blockLen % bs)) % bs
-	pk1.Pad = make([]byte, padLen)
+	var encrypt func([]byte)
+	if len(opts.Passphrase) > 0 {
+		w.CipherName, w.KdfName, w.KdfOpts, encrypt, err = bcryptAES256CTR(opts)
+		if err != nil {
+			return nil, err
+		}
+		bs = aes.BlockSize
+	}
+
+	// Add some padding to match the encryption block size within PrivKeyBlock
+	block := ssh.Marshal(pk1)
+	padLen := (bs - (len(block) % bs)) % bs
 
 	// Padding is a sequence of bytes like: 1, 2, 3...
 	for i := range padLen {
-		pk1.Pad[i] = byte(i + 1)
+		block = append(block, byte(i+1))
+	}
+	if encrypt != nil {
+		encrypt(block)
 	}
 
-	// Generate the pubkey prefix "\0\0\0\nssh-ed25519\0\0\0 "
-	prefix := []byte{0x0, 0x0, 0x0, 0x0b}
-	prefix = append(prefix, []byte(ssh.KeyAlgoED25519)...)
-	prefix = append(prefix, []byte{0x0, 0x0, 0x0, 0x20}...)
-
-	// Only going to support unencrypted keys for now
-	w.CipherName = "none"
-	w.KdfName = "none"
-	w.KdfOpts = ""
 	w.NumKeys = 1
-	w.PubKey = append(prefix, pubKey...)
-	w.PrivKeyBlock = ssh.Marshal(pk1)
+	w.PubKey = pubKey.Marshal()
+	w.PrivKeyBlock = block
 
 	magic = append(magic, ssh.Marshal(w)...)
 
-	return magic
+	return magic, nil
+}
+
+// bcryptAES256CTR returns the header fields and the encryption function for a
+// private key block protected by opts.Passphrase.
+func bcryptAES256CTR(opts Options) (string, string, string, func([]byte), error) {
+	rounds := opts.Rounds
+	if rounds == 0 {
+		rounds = DefaultRounds
+	}
+	if rounds < 1 || uint64(rounds) > math.MaxUint32 {
+		return "", "", "", nil, fmt.Errorf("edkey: invalid number of bcrypt rounds %d", rounds)
+	}
+	salt := make([]byte, 16)
+	if _, err := crand.Read(salt); err != nil {
+		return "", "", "", nil, err
+	}
+	k, err := bcryptPBKDF(opts.Passphrase, salt, rounds, 32+aes.BlockSize)
+	if err != nil {
+		return "", "", "", nil, err
+	}
+	c, err := aes.NewCipher(k[:32])
+	if err != nil {
+		return "", "", "", nil, err
+	}
+	kdfOpts := ssh.Marshal(struct {
+		Salt   []byte
+		Rounds uint32
+	}{salt, uint32(rounds)})
+
+	encrypt := func(b []byte) {
+		cipher.NewCTR(c, k[32:]).XORKeyStream(b, b)
+	}
+	return "aes256-ctr", "bcrypt", string(kdfOpts), encrypt, nil
 }
@@ -1,8 +1,13 @@
 package edkey
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
 	"encoding/pem"
 	"testing"
 
@@ -32,3 +37,99 @@ func TestMarshalED25519PrivateKey(t *testing.T) {
 		t.Errorf("Failed to parse marshaled private key: %v", err)
 	}
 }
+
+func TestMarshalPrivateKey(t *testing.T) {
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ed25519 key: %v", err)
+	}
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ecdsa key: %v", err)
+	}
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate rsa key: %v", err)
+	}
+
+	for name, key := range map[string]crypto.Signer{"ed25519": edPriv, "ecdsa": ecPriv, "rsa": rsaPriv} {
+		t.Run(name, func(t *testing.T) {
+			marshaled, err := MarshalPrivateKey(key, Options{})
+			if err != nil {
+				t.Fatalf("MarshalPrivateKey failed: %v", err)
+			}
+			parsed, err := ssh.ParseRawPrivateKey(pem.EncodeToMemory(&pem.Block{
+				Type:  "OPENSSH PRIVATE KEY",
+				Bytes: marshaled,
+			}))
+			if err != nil {
+				t.Fatalf("Failed to parse marshaled private key: %v", err)
+			}
+			if !key.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(parsed.(crypto.Signer).Public()) {
+				t.Error("Parsed private key does not match")
+			}
+		})
+	}
+}
+
+func TestMarshalPrivateKeyWithPassphrase(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ed25519 key: %v", err)
+	}
+	passphrase := []byte("correct horse battery staple")
+
+	marshaled, err := MarshalPrivateKey(priv, Options{Passphrase: passphrase, Rounds: 4})
+	if err != nil {
+		t.Fatalf("MarshalPrivateKey failed: %v", err)
+	}
+	privatePEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: marshaled,
+	})
+
+	if _, err := ssh.ParseRawPrivateKey(privatePEM); err == nil {
+		t.Error("Expected encrypted key to require a passphrase")
+	}
+	if _, err := ssh.ParseRawPrivateKeyWithPassphrase(privatePEM, []byte("wrong")); err == nil {
+		t.Error("Expected wrong passphrase to fail")
+	}
+	parsed, err := ssh.ParseRawPrivateKeyWithPassphrase(privatePEM, passphrase)
+	if err != nil {
+		t.Fatalf("Failed to parse encrypted private key: %v", err)
+	}
+	if !priv.Equal(*parsed.(*ed25519.PrivateKey)) {
+		t.Error("Parsed private key does not match")
+	}
+}
+
+func TestMarshalPrivateKeyInvalidRounds(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ed25519 key: %v", err)
+	}
+	if _, err := MarshalPrivateKey(priv, Options{Passphrase: []byte("x"), Rounds: -1}); err == nil {
+		t.Error("Expected negative rounds to fail")
+	}
+}
+
+func TestMarshalPrivateKeyComment(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ed25519 key: %v", err)
+	}
+
+	marshaled, err := MarshalPrivateKey(priv, Options{Comment: "alice@corp"})
+	if err != nil {
+		t.Fatalf("MarshalPrivateKey failed: %v", err)
+	}
+	if !bytes.Contains(marshaled, []byte("\x00\x00\x00\x0aalice@corp")) {
+		t.Error("Comment not found in private key block")
+	}
+	if _, err := ssh.ParseRawPrivateKey(pem.EncodeToMemory(&pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: marshaled,
+	})); err != nil {
+		t.Errorf("Failed to parse marshaled private key: %v", err)
+	}
+}
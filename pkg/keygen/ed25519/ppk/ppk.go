@@ -0,0 +1,185 @@
+package ppk
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/ssh"
+)
+
+// Default Argon2id parameters. PuTTYgen uses the same memory and parallelism
+// and calibrates the number of passes to take about 100ms.
+const (
+	DefaultArgon2Memory      = 8192
+	DefaultArgon2Passes      = 13
+	DefaultArgon2Parallelism = 1
+)
+
+const (
+	// lineLen is the number of base64 characters per line, like PuTTYgen.
+	lineLen = 64
+
+	keyLen = 32
+	ivLen  = aes.BlockSize
+	macLen = 32
+)
+
+// Options control how MarshalPrivateKey writes a private key.
+type Options struct {
+	// Passphrase encrypts the private key with aes256-cbc, using a key
+	// derived with Argon2id. The key is written unencrypted if empty.
+	Passphrase []byte
+	// Comment is shown by PuTTY and Pageant for the key.
+	Comment string
+	// Argon2Memory is in KiB, DefaultArgon2Memory if zero.
+	Argon2Memory uint32
+	// Argon2Passes is DefaultArgon2Passes if zero.
+	Argon2Passes uint32
+	// Argon2Parallelism is DefaultArgon2Parallelism if zero.
+	Argon2Parallelism uint8
+}
+
+// MarshalPrivateKey writes ed25519, ecdsa and rsa private keys into the PuTTY
+// private key file format version 3, optionally encrypted with a passphrase.
+func MarshalPrivateKey(key crypto.PrivateKey, opts Options) ([]byte, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("ppk: unsupported key type %T", key)
+	}
+	pubKey, err := ssh.NewPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	priv, err := privateBlob(key)
+	if err != nil {
+		return nil, err
+	}
+
+	encryption := "none"
+	var kdfHeaders []byte
+	var cipherKey, iv, macKey []byte
+	if len(opts.Passphrase) > 0 {
+		encryption = "aes256-cbc"
+		memory, passes, parallelism := argon2Params(opts)
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		k := argon2.IDKey(opts.Passphrase, salt, passes, memory, parallelism, keyLen+ivLen+macLen)
+		cipherKey, iv, macKey = k[:keyLen], k[keyLen:keyLen+ivLen], k[keyLen+ivLen:]
+		kdfHeaders = fmt.Appendf(nil,
+			"Key-Derivation: Argon2id\nArgon2-Memory: %d\nArgon2-Passes: %d\nArgon2-Parallelism: %d\nArgon2-Salt: %x\n",
+			memory, passes, parallelism, salt)
+
+		// Pad with random bytes to the cipher block size, PuTTY ignores
+		// anything after the key fields.
+		if n := len(priv) % aes.BlockSize; n != 0 {
+			pad := make([]byte, aes.BlockSize-n)
+			if _, err := rand.Read(pad); err != nil {
+				return nil, err
+			}
+			priv = append(priv, pad...)
+		}
+	}
+
+	// The MAC covers the padded private blob before encryption, unencrypted
+	// keys use an empty MAC key.
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(ssh.Marshal(struct {
+		Algorithm  string
+		Encryption string
+		Comment    string
+		Public     []byte
+		Private    []byte
+	}{pubKey.Type(), encryption, opts.Comment, pubKey.Marshal(), priv}))
+
+	if cipherKey != nil {
+		c, err := aes.NewCipher(cipherKey)
+		if err != nil {
+			return nil, err
+		}
+		cipher.NewCBCEncrypter(c, iv).CryptBlocks(priv, priv)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "PuTTY-User-Key-File-3: %s\n", pubKey.Type())
+	fmt.Fprintf(&b, "Encryption: %s\n", encryption)
+	fmt.Fprintf(&b, "Comment: %s\n", opts.Comment)
+	writeLines(&b, "Public-Lines", pubKey.Marshal())
+	b.Write(kdfHeaders)
+	writeLines(&b, "Private-Lines", priv)
+	fmt.Fprintf(&b, "Private-MAC: %s\n", hex.EncodeToString(mac.Sum(nil)))
+	return b.Bytes(), nil
+}
+
+func argon2Params(opts Options) (uint32, uint32, uint8) {
+	memory, passes, parallelism := opts.Argon2Memory, opts.Argon2Passes, opts.Argon2Parallelism
+	if memory == 0 {
+		memory = DefaultArgon2Memory
+	}
+	if passes == 0 {
+		passes = DefaultArgon2Passes
+	}
+	if parallelism == 0 {
+		parallelism = DefaultArgon2Parallelism
+	}
+	return memory, passes, parallelism
+}
+
+// privateBlob returns the key type specific private fields, as PuTTY stores
+// them.
+func privateBlob(key crypto.PrivateKey) ([]byte, error) {
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		// PuTTY stores the seed as an unsigned little endian integer,
+		// without its most significant zero bytes.
+		seed := bytes.TrimRight(k.Seed(), "\x00")
+		return ssh.Marshal(struct{ Seed []byte }{seed}), nil
+	case *ecdsa.PrivateKey:
+		return ssh.Marshal(struct{ D *big.Int }{k.D}), nil
+	case *rsa.PrivateKey:
+		if len(k.Primes) != 2 {
+			return nil, errors.New("ppk: multi-prime rsa keys are not supported")
+		}
+		iqmp := k.Precomputed.Qinv
+		if iqmp == nil {
+			iqmp = new(big.Int).ModInverse(k.Primes[1], k.Primes[0])
+		}
+		return ssh.Marshal(struct {
+			D    *big.Int
+			P    *big.Int
+			Q    *big.Int
+			Iqmp *big.Int
+		}{k.D, k.Primes[0], k.Primes[1], iqmp}), nil
+	default:
+		return nil, fmt.Errorf("ppk: unsupported key type %T", key)
+	}
+}
+
+// writeLines writes data as a header with the line count followed by the
+// base64 encoded lines.
+func writeLines(b *bytes.Buffer, header string, data []byte) {
+	s := base64.StdEncoding.EncodeToString(data)
+	fmt.Fprintf(b, "%s: %d\n", header, (len(s)+lineLen-1)/lineLen)
+	for len(s) > lineLen {
+		b.WriteString(s[:lineLen])
+		b.WriteByte('\n')
+		s = s[lineLen:]
+	}
+	b.WriteString(s)
+	b.WriteByte('\n')
+}
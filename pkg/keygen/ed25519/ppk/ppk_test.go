@@ -0,0 +1,203 @@
+package ppk
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/ssh"
+)
+
+// parse reads a PPK v3 file and returns the headers, the public and the
+// decrypted private blob, and whether the MAC matches.
+func parse(t *testing.T, b []byte, passphrase []byte) (map[string]string, []byte, []byte, bool) {
+	t.Helper()
+	headers := map[string]string{}
+	blobs := map[string][]byte{}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		name, value, ok := strings.Cut(sc.Text(), ": ")
+		if !ok {
+			t.Fatalf("Invalid line %q", sc.Text())
+		}
+		if name == "Public-Lines" || name == "Private-Lines" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var s string
+			for range n {
+				sc.Scan()
+				if len(sc.Text()) > 64 {
+					t.Errorf("Line longer than 64 characters: %q", sc.Text())
+				}
+				s += sc.Text()
+			}
+			blob, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			blobs[name] = blob
+		}
+		headers[name] = value
+	}
+
+	pub, priv := blobs["Public-Lines"], blobs["Private-Lines"]
+	var macKey []byte
+	if headers["Encryption"] == "aes256-cbc" {
+		if headers["Key-Derivation"] != "Argon2id" {
+			t.Fatalf("Unexpected key derivation %q", headers["Key-Derivation"])
+		}
+		memory, _ := strconv.Atoi(headers["Argon2-Memory"])
+		passes, _ := strconv.Atoi(headers["Argon2-Passes"])
+		parallelism, _ := strconv.Atoi(headers["Argon2-Parallelism"])
+		salt, _ := hex.DecodeString(headers["Argon2-Salt"])
+		//nolint:gosec // Test values are small.
+		k := argon2.IDKey(passphrase, salt, uint32(passes), uint32(memory), uint8(parallelism), 80)
+		c, err := aes.NewCipher(k[:32])
+		if err != nil {
+			t.Fatal(err)
+		}
+		cipher.NewCBCDecrypter(c, k[32:48]).CryptBlocks(priv, priv)
+		macKey = k[48:]
+	}
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(ssh.Marshal(struct {
+		Algorithm, Encryption, Comment string
+		Public, Private                []byte
+	}{headers["PuTTY-User-Key-File-3"], headers["Encryption"], headers["Comment"], pub, priv}))
+	return headers, pub, priv, hex.EncodeToString(mac.Sum(nil)) == headers["Private-MAC"]
+}
+
+func TestMarshalPrivateKey(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		key   crypto.Signer
+		check func(t *testing.T, priv []byte)
+	}{
+		{"ed25519", edKey, func(t *testing.T, priv []byte) {
+			var p struct {
+				Seed []byte
+				Rest []byte `ssh:"rest"`
+			}
+			if err := ssh.Unmarshal(priv, &p); err != nil {
+				t.Fatal(err)
+			}
+			seed := make([]byte, ed25519.SeedSize)
+			copy(seed, p.Seed)
+			if !ed25519.NewKeyFromSeed(seed).Equal(edKey) {
+				t.Error("Private key does not match")
+			}
+		}},
+		{"ecdsa", ecKey, func(t *testing.T, priv []byte) {
+			var p struct {
+				D    *big.Int
+				Rest []byte `ssh:"rest"`
+			}
+			if err := ssh.Unmarshal(priv, &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.D.Cmp(ecKey.D) != 0 {
+				t.Error("Private key does not match")
+			}
+		}},
+		{"rsa", rsaKey, func(t *testing.T, priv []byte) {
+			var p struct {
+				D, P, Q, Iqmp *big.Int
+				Rest          []byte `ssh:"rest"`
+			}
+			if err := ssh.Unmarshal(priv, &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.D.Cmp(rsaKey.D) != 0 || p.P.Cmp(rsaKey.Primes[0]) != 0 ||
+				p.Q.Cmp(rsaKey.Primes[1]) != 0 || p.Iqmp.Cmp(rsaKey.Precomputed.Qinv) != 0 {
+				t.Error("Private key does not match")
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		for _, passphrase := range []string{"", "secret"} {
+			t.Run(tt.name+"/"+strconv.Quote(passphrase), func(t *testing.T) {
+				b, err := MarshalPrivateKey(tt.key, Options{
+					Passphrase:   []byte(passphrase),
+					Comment:      "alice@corp",
+					Argon2Passes: 1,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				headers, pub, priv, macOK := parse(t, b, []byte(passphrase))
+				if !macOK {
+					t.Fatal("Private-MAC does not match")
+				}
+
+				sshPub, err := ssh.NewPublicKey(tt.key.Public())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if headers["PuTTY-User-Key-File-3"] != sshPub.Type() {
+					t.Errorf("Unexpected key type %q", headers["PuTTY-User-Key-File-3"])
+				}
+				if headers["Comment"] != "alice@corp" {
+					t.Errorf("Unexpected comment %q", headers["Comment"])
+				}
+				if !bytes.Equal(pub, sshPub.Marshal()) {
+					t.Error("Public key does not match")
+				}
+				wantEncryption := "none"
+				if passphrase != "" {
+					wantEncryption = "aes256-cbc"
+				}
+				if headers["Encryption"] != wantEncryption {
+					t.Errorf("Unexpected encryption %q", headers["Encryption"])
+				}
+				tt.check(t, priv)
+			})
+		}
+	}
+}
+
+func TestMarshalPrivateKeyWrongPassphrase(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := MarshalPrivateKey(key, Options{Passphrase: []byte("secret"), Argon2Passes: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, macOK := parse(t, b, []byte("wrong")); macOK {
+		t.Error("Expected the MAC check to fail with a wrong passphrase")
+	}
+}
@@ -1,13 +1,24 @@
 package keygen
 
 import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/pem"
 	"errors"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
 	"testing"
 
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/drbg"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ecdsa"
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519/edkey"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/rsa"
 )
 
@@ -15,21 +26,182 @@ func TestSshAdd_RSA2048(t *testing.T) {
 	if os.Getenv("CI") != "" {
 		t.Skip("Skipping testing in CI environment")
 	}
-	SSHAddCompatible(t, rsa.New(2048))
+	SSHAddCompatible(t, rsa.New(rand.Reader, 2048, rsa.OpenSSH))
 }
 
 func TestSshAdd_RSA4096(t *testing.T) {
 	if os.Getenv("CI") != "" {
 		t.Skip("Skipping testing in CI environment")
 	}
-	SSHAddCompatible(t, rsa.New(4096))
+	SSHAddCompatible(t, rsa.New(rand.Reader, 4096, rsa.OpenSSH))
+}
+
+func TestSshAdd_RSA2048_PKCS1(t *testing.T) {
+	if os.Getenv("CI") != "" {
+		t.Skip("Skipping testing in CI environment")
+	}
+	SSHAddCompatible(t, rsa.New(rand.Reader, 2048, rsa.PKCS1))
+}
+
+func TestSshAdd_RSA2048_Exponent(t *testing.T) {
+	if os.Getenv("CI") != "" {
+		t.Skip("Skipping testing in CI environment")
+	}
+	SSHAddCompatible(t, rsa.NewExponentSearch(rand.Reader, 2048, rsa.OpenSSH))
 }
 
 func TestSshAdd_ED25519(t *testing.T) {
 	if os.Getenv("CI") != "" {
 		t.Skip("Skipping testing in CI environment")
 	}
-	SSHAddCompatible(t, ed25519.New())
+	SSHAddCompatible(t, ed25519.New(rand.Reader))
+}
+
+func TestSshAdd_ECDSA(t *testing.T) {
+	if os.Getenv("CI") != "" {
+		t.Skip("Skipping testing in CI environment")
+	}
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		t.Run(curve.Params().Name, func(t *testing.T) {
+			SSHAddCompatible(t, ecdsa.New(rand.Reader, curve))
+		})
+	}
+}
+
+func TestSshAdd_RSA2048_Seeded(t *testing.T) {
+	if os.Getenv("CI") != "" {
+		t.Skip("Skipping testing in CI environment")
+	}
+	SSHAddCompatible(t, rsa.New(drbg.New([]byte("seed"), 0), 2048, rsa.OpenSSH))
+}
+
+func TestSeededKeysAreReproducible(t *testing.T) {
+	keygens := map[string]Keygen{
+		"ed25519":    func(r io.Reader) SSHKey { return ed25519.New(r) },
+		"ecdsa-p256": func(r io.Reader) SSHKey { return ecdsa.New(r, elliptic.P256()) },
+		"ecdsa-p521": func(r io.Reader) SSHKey { return ecdsa.New(r, elliptic.P521()) },
+		"rsa-2048":   func(r io.Reader) SSHKey { return rsa.New(r, 2048, rsa.OpenSSH) },
+	}
+	for name, kg := range keygens {
+		t.Run(name, func(t *testing.T) {
+			generate := func(worker int, attempt uint64) SSHKey {
+				r := drbg.New([]byte("seed"), worker)
+				r.SetAttempt(attempt)
+				k := kg(r)
+				k.Generate()
+				return k
+			}
+			k := generate(1, 7)
+			if !bytes.Equal(generate(1, 7).SSHPubkey(), k.SSHPubkey()) {
+				t.Error("Same seed, worker and attempt gave a different key")
+			}
+			if bytes.Equal(generate(1, 8).SSHPubkey(), k.SSHPubkey()) {
+				t.Error("Different attempts gave the same key")
+			}
+			if bytes.Equal(generate(2, 7).SSHPubkey(), k.SSHPubkey()) {
+				t.Error("Different workers gave the same key")
+			}
+			if _, err := ssh.ParseRawPrivateKey(k.SSHPrivkey()); err != nil {
+				t.Errorf("Failed to parse private key: %v", err)
+			}
+		})
+	}
+}
+
+func TestLayouts(t *testing.T) {
+	keygens := map[string]Keygen{
+		"ed25519":      func(r io.Reader) SSHKey { return ed25519.New(r) },
+		"ecdsa-p256":   func(r io.Reader) SSHKey { return ecdsa.New(r, elliptic.P256()) },
+		"ecdsa-p521":   func(r io.Reader) SSHKey { return ecdsa.New(r, elliptic.P521()) },
+		"rsa-2048":     func(r io.Reader) SSHKey { return rsa.New(r, 2048, rsa.OpenSSH) },
+		"rsa-exponent": func(r io.Reader) SSHKey { return rsa.NewExponentSearch(r, 2048, rsa.OpenSSH) },
+	}
+	for name, kg := range keygens {
+		t.Run(name, func(t *testing.T) {
+			k := kg(rand.Reader)
+			l := k.(Layouter).Layout()
+			k.Generate()
+			header := k.SSHPubkey()[:l.Start]
+			for range 20 {
+				k.Generate()
+				pubK := k.SSHPubkey()
+				if !bytes.HasPrefix(pubK, header) {
+					t.Fatalf("Expected the header %q in %q", header, pubK)
+				}
+				if bytes.ContainsAny(pubK[l.End:], layout.Base64Alphabet) {
+					t.Fatalf("Expected only padding after %d in %q", l.End, pubK)
+				}
+				for i := l.Start; i < l.End; i++ {
+					if !strings.ContainsRune(l.Symbols(i), rune(pubK[i])) {
+						t.Fatalf("Expected one of %q at %d in %q", l.Symbols(i), i, pubK)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSshKeygen_Passphrase(t *testing.T) {
+	if os.Getenv("CI") != "" {
+		t.Skip("Skipping testing in CI environment")
+	}
+	t.Run("ed25519", func(t *testing.T) {
+		SSHKeygenPassphraseCompatible(t, ed25519.New(rand.Reader))
+	})
+	t.Run("ecdsa", func(t *testing.T) {
+		SSHKeygenPassphraseCompatible(t, ecdsa.New(rand.Reader, elliptic.P256()))
+	})
+	t.Run("rsa", func(t *testing.T) {
+		SSHKeygenPassphraseCompatible(t, rsa.New(rand.Reader, 2048, rsa.OpenSSH))
+	})
+}
+
+func SSHKeygenPassphraseCompatible(t *testing.T, k SSHKey) {
+	k.Generate()
+	passphrase := "vanity passphrase"
+	privDER, err := edkey.MarshalPrivateKey(k.(CryptoKey).PrivateKey(), edkey.Options{
+		Passphrase: []byte(passphrase),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: privDER})
+
+	dir := t.TempDir()
+	keyfile := dir + "/k"
+	if err := os.WriteFile(keyfile, pk, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	{
+		// #nosec G204
+		out, err := exec.Command("ssh-keygen", "-y", "-P", passphrase, "-f", keyfile).CombinedOutput()
+		t.Logf("%s", out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(bytes.TrimSpace(out), bytes.TrimSpace(k.SSHPubkey())) {
+			t.Errorf("Expected public key %s", k.SSHPubkey())
+		}
+	}
+
+	{
+		askpass := dir + "/askpass"
+		if err := os.WriteFile(askpass, []byte("#!/bin/sh\necho '"+passphrase+"'\n"), 0o700); err != nil { //nolint:gosec // Test helper script.
+			t.Fatal(err)
+		}
+		// #nosec G204
+		cmd := exec.Command("ssh-add", "-t", "1", keyfile)
+		cmd.Env = append(os.Environ(), "SSH_ASKPASS="+askpass, "SSH_ASKPASS_REQUIRE=force")
+		out, err := cmd.CombinedOutput()
+		t.Logf("%s", out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(out), "Identity added:") {
+			t.Fail()
+		}
+	}
 }
 
 func SSHAddCompatible(t *testing.T, k SSHKey) {
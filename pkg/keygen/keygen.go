@@ -1,12 +1,55 @@
 package keygen
 
+import (
+	"crypto"
+	"io"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
 type SSHKey interface {
 	SSHPubkey() []byte
 	SSHPrivkey() []byte
 	Generate()
 }
 
-type Keygen func() SSHKey
+// CryptoKey is implemented by keys that expose their underlying private key,
+// so it can be written in other formats than the one SSHPrivkey returns.
+type CryptoKey interface {
+	PrivateKey() crypto.Signer
+}
+
+// Layouter is implemented by keys that know which characters of their public
+// key depend on the key, before a key is generated.
+type Layouter interface {
+	Layout() layout.Layout
+}
+
+// Blober is implemented by keys that expose the binary public key blob that
+// the authorized_keys line is the base64 encoding of. Matchers that work on the
+// blob let these keys skip the encoding until a key matches.
+type Blober interface {
+	Blob() []byte
+}
+
+// AttemptReader is a deterministic entropy source. The worker positions it at
+// every attempt before generating a key, so that a key can be reproduced from
+// the reader's seed and the attempt number.
+type AttemptReader interface {
+	io.Reader
+	SetAttempt(attempt uint64)
+}
+
+// Replayer is implemented by keys that derive candidates from the stream of an
+// earlier attempt, like the RSA exponent search stepping through the exponents
+// of one modulus. Replay returns how many attempts before the current one the
+// key read its stream, generating again from that attempt reproduces the key.
+type Replayer interface {
+	Replay() int
+}
+
+// Keygen creates a key that reads its entropy from rand.
+type Keygen func(rand io.Reader) SSHKey
 
 type namedKeygen struct {
 	name   string
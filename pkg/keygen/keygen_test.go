@@ -1,6 +1,7 @@
 package keygen
 
 import (
+	"io"
 	"slices"
 	"testing"
 )
@@ -13,7 +14,7 @@ func (m *mockKey) Generate()          {}
 
 func TestRegistry(t *testing.T) {
 	name := "mock"
-	keygenFunc := func() SSHKey { return &mockKey{} }
+	keygenFunc := func(io.Reader) SSHKey { return &mockKey{} }
 
 	RegisterKeygen(name, keygenFunc)
 
@@ -0,0 +1,438 @@
+package layout
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Base64Alphabet holds the symbols of standard base64, in value order.
+	Base64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+	// Ed25519Start is the offset of the first character of an ed25519 public
+	// key line that comes from the key itself, after "ssh-ed25519 " and the
+	// encoded key type.
+	Ed25519Start = 37
+	// MaxBlobLen fits the public key blobs of all registered key types, up
+	// to RSA-4096, so they can be decoded on the stack.
+	MaxBlobLen = 1024
+)
+
+// Anchor selects where in the public key a match string has to be.
+type Anchor string
+
+const (
+	// AnchorAny matches anywhere in the public key line.
+	AnchorAny Anchor = "any"
+	// AnchorStart matches right after the fixed header of the key type.
+	AnchorStart Anchor = "start"
+	// AnchorEnd matches at the end of the key, before padding and comment.
+	AnchorEnd Anchor = "end"
+)
+
+// Layout describes which characters of a key type's authorized_keys line
+// depend on the key, and which base64 symbols each of them can take. The key
+// type, the encoded blob header and the padding are the same for every key,
+// and the characters at the edges share bits with them.
+type Layout struct {
+	// Prefix is the offset of the base64 blob, after the key type and a
+	// space.
+	Prefix int
+	// Start is the offset of the first character that depends on the key.
+	Start int
+	// End is the offset after the last character that depends on the key.
+	End int
+	// keyType is the key type the line starts with.
+	keyType string
+	// symbols holds the possible symbols of every character of the line,
+	// from the key type to the padding.
+	symbols []string
+	// shared marks the characters that only change every keys candidates,
+	// the others change with every candidate.
+	shared []bool
+	keys   int
+}
+
+// New returns the layout of the line "<keyType> <base64 blob>". free
+// has the same length as blob, its set bits are the bits of the blob that
+// depend on the key. The other bits are taken from blob.
+func New(keyType string, blob, free []byte) Layout {
+	prefix := len(keyType) + 1
+	l := Layout{Prefix: prefix, Start: -1, keyType: keyType}
+	for _, c := range []byte(keyType + " ") {
+		l.symbols = append(l.symbols, string(c))
+	}
+	for i := range base64.StdEncoding.EncodedLen(len(blob)) {
+		if i >= base64.RawStdEncoding.EncodedLen(len(blob)) {
+			l.symbols = append(l.symbols, "=")
+			continue
+		}
+		value, mask := sextet(blob, i), sextet(free, i)
+		if mask == 0 {
+			l.symbols = append(l.symbols, Base64Alphabet[value:value+1])
+			continue
+		}
+		if l.Start < 0 {
+			l.Start = prefix + i
+		}
+		var symbols []byte
+		for v := range byte(64) {
+			if v&^mask == value&^mask {
+				symbols = append(symbols, Base64Alphabet[v])
+			}
+		}
+		l.symbols = append(l.symbols, string(symbols))
+		l.End = prefix + i + 1
+	}
+	if l.Start < 0 {
+		return Layout{}
+	}
+	return l
+}
+
+// ed25519Header is the start of an ed25519 public key blob: the key type and
+// the length of the 32 byte key.
+const ed25519Header = "\x00\x00\x00\x0bssh-ed25519\x00\x00\x00\x20"
+
+// Ed25519 returns the layout of ed25519 keys.
+func Ed25519() Layout {
+	var blob, free [len(ed25519Header) + 32]byte
+	copy(blob[:], ed25519Header)
+	for i := len(ed25519Header); i < len(free); i++ {
+		free[i] = 0xff
+	}
+	return New("ssh-ed25519", blob[:], free[:])
+}
+
+// OrEd25519 returns l, or the ed25519 layout if l is not set. Matchers
+// estimate their difficulty for ed25519 keys until they get the key layout.
+func (l Layout) OrEd25519() Layout {
+	if l.End > 0 {
+		return l
+	}
+	return Ed25519()
+}
+
+// Share returns the layout of a key type whose candidates share the bits set
+// in shared, which has the length of the blob of New. They change only every
+// keys candidates, so a match string there has a new chance once per keys
+// candidates instead of with every one.
+func (l Layout) Share(shared []byte, keys int) Layout {
+	if l.End == 0 {
+		return l
+	}
+	l.shared = make([]bool, len(l.symbols))
+	for i := range base64.RawStdEncoding.EncodedLen(len(shared)) {
+		l.shared[l.Prefix+i] = sextet(shared, i) != 0
+	}
+	l.keys = keys
+	return l
+}
+
+// Shared reports if the character at offset is shared between candidates.
+func (l Layout) Shared(offset int) bool {
+	return offset >= 0 && offset < len(l.shared) && l.shared[offset]
+}
+
+// sextet returns the bits of the i:th base64 character of b, with bits past
+// the end of b zero like in padded base64.
+func sextet(b []byte, i int) byte {
+	bit := i * 6
+	var v uint16
+	if bit/8 < len(b) {
+		v = uint16(b[bit/8]) << 8
+	}
+	if bit/8+1 < len(b) {
+		v |= uint16(b[bit/8+1])
+	}
+	return byte(v>>(10-bit%8)) & 0x3f
+}
+
+// Symbols returns the base64 symbols the character at offset can take, or an
+// empty string outside of the key.
+func (l Layout) Symbols(offset int) string {
+	if offset < l.Start || offset >= l.End {
+		return ""
+	}
+	return l.symbols[offset]
+}
+
+// Offset returns where a match string of n characters has to be for the
+// anchor. position counts characters after the start, or before the end. The
+// offset is -1 for AnchorAny.
+func (l Layout) Offset(anchor Anchor, position, n int) (int, error) {
+	var offset int
+	switch anchor {
+	case AnchorAny:
+		return -1, nil
+	case AnchorStart:
+		offset = l.Start + position
+	case AnchorEnd:
+		offset = l.End - position - n
+	default:
+		return 0, fmt.Errorf("unknown anchor %q", anchor)
+	}
+	if position < 0 || offset < l.Start || offset+n > l.End {
+		return 0, fmt.Errorf("%d characters at position %d do not fit in the %d characters of the key", n, position, l.End-l.Start)
+	}
+	return offset, nil
+}
+
+// Check returns an error if matchString can never be at offset, because one
+// of its characters is not among the symbols there. The error suggests the
+// nearest match string that can.
+func (l Layout) Check(offset int, matchString string, ignoreCase bool) error {
+	for i := range len(matchString) {
+		if l.Probability(offset+i, matchString[i:i+1], ignoreCase) == 0 {
+			return fmt.Errorf("%q can not be at position %d of the key, it is one of %q, try %q",
+				matchString[i], offset+i-l.Start, l.Symbols(offset+i), l.suggest(offset, matchString, ignoreCase))
+		}
+	}
+	return nil
+}
+
+// Fits returns an error if matchString can not be anywhere in the line from
+// offset from on, including the fixed characters around the key. The error
+// suggests the nearest match string that fits in the key.
+func (l Layout) Fits(from int, matchString string, ignoreCase bool) error {
+	if len(matchString) > l.End-l.Start {
+		return fmt.Errorf("%q is longer than the %d characters of a %s key", matchString, l.End-l.Start, l.keyType)
+	}
+	for offset := from; offset+len(matchString) <= len(l.symbols); offset++ {
+		if l.possible(offset, matchString, ignoreCase) {
+			return nil
+		}
+	}
+	// The suggestion that changes the fewest characters.
+	suggestion, changes := "", len(matchString)+1
+	for offset := l.Start; offset+len(matchString) <= l.End; offset++ {
+		s := l.suggest(offset, matchString, ignoreCase)
+		n := 0
+		for i := range len(s) {
+			if s[i] != matchString[i] {
+				n++
+			}
+		}
+		if n < changes {
+			suggestion, changes = s, n
+		}
+	}
+	return fmt.Errorf("%q fits nowhere in a %s key, try %q", matchString, l.keyType, suggestion)
+}
+
+// possible reports if matchString can be at offset of the line.
+func (l Layout) possible(offset int, matchString string, ignoreCase bool) bool {
+	for i := range len(matchString) {
+		if !strings.Contains(l.symbols[offset+i], matchString[i:i+1]) &&
+			!(ignoreCase && strings.Contains(strings.ToLower(l.symbols[offset+i]), strings.ToLower(matchString[i:i+1]))) {
+			return false
+		}
+	}
+	return true
+}
+
+// suggest replaces the characters of matchString that can not be at offset of
+// the key with the nearest symbol that can: the other case of a letter, or
+// else the symbol with the closest bits.
+func (l Layout) suggest(offset int, matchString string, ignoreCase bool) string {
+	s := []byte(matchString)
+	for i, c := range s {
+		symbols := l.Symbols(offset + i)
+		if symbols == "" || l.Probability(offset+i, string(c), ignoreCase) > 0 {
+			continue
+		}
+		if other := swapCase(c); other != c && strings.IndexByte(symbols, other) >= 0 {
+			s[i] = other
+			continue
+		}
+		s[i] = symbols[0]
+		for _, symbol := range []byte(symbols) {
+			if distance(symbol, c) < distance(s[i], c) {
+				s[i] = symbol
+			}
+		}
+	}
+	return string(s)
+}
+
+// distance returns how far apart the values of two base64 symbols are.
+func distance(a, b byte) int {
+	d := strings.IndexByte(Base64Alphabet, a) - strings.IndexByte(Base64Alphabet, b)
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func swapCase(c byte) byte {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return c - 'a' + 'A'
+	case c >= 'A' && c <= 'Z':
+		return c - 'A' + 'a'
+	}
+	return c
+}
+
+// lookalikes are the base64 symbols of characters that are not base64: the
+// base64url alphabet uses "-" and "_" instead of "+" and "/".
+var lookalikes = map[byte]byte{'-': '+', '_': '/'}
+
+// CheckBase64 returns an error if matchString has characters that are never
+// in the base64 text of a key. The error suggests the nearest match string
+// that is base64, with base64url characters swapped and others left out.
+func CheckBase64(matchString string) error {
+	if matchString == "" {
+		return errors.New("empty match string")
+	}
+	var invalid []string
+	suggestion := make([]byte, 0, len(matchString))
+	for _, c := range []byte(matchString) {
+		if strings.IndexByte(Base64Alphabet, c) >= 0 {
+			suggestion = append(suggestion, c)
+			continue
+		}
+		if q := strconv.QuoteRune(rune(c)); !slices.Contains(invalid, q) {
+			invalid = append(invalid, q)
+		}
+		if symbol, ok := lookalikes[c]; ok {
+			suggestion = append(suggestion, symbol)
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+	verb := "is"
+	if len(invalid) > 1 {
+		verb = "are"
+	}
+	err := fmt.Errorf("%q can never match, %s %s not base64", matchString, strings.Join(invalid, ", "), verb)
+	if len(suggestion) == 0 {
+		return fmt.Errorf("%w, only A-Z, a-z, 0-9, + and / are", err)
+	}
+	return fmt.Errorf("%w, try %q", err, suggestion)
+}
+
+// DecodeBlob decodes the base64 public key blob of an authorized_keys line
+// into buf, or a new slice if buf is too small.
+func DecodeBlob(pubK, buf []byte) ([]byte, bool) {
+	_, encoded, ok := bytes.Cut(pubK, []byte(" "))
+	if !ok {
+		return nil, false
+	}
+	if i := bytes.IndexAny(encoded, " \n"); i >= 0 {
+		encoded = encoded[:i]
+	}
+	if base64.StdEncoding.DecodedLen(len(encoded)) > len(buf) {
+		buf = make([]byte, base64.StdEncoding.DecodedLen(len(encoded)))
+	}
+	n, err := base64.StdEncoding.Decode(buf, encoded)
+	if err != nil {
+		return nil, false
+	}
+	return buf[:n], true
+}
+
+// Difficulty returns the expected number of keys to test until matchString
+// is found at offset, or anywhere in the key if offset is -1. It is infinite
+// if the match string can never be there.
+func (l Layout) Difficulty(offset int, matchString string, ignoreCase bool) float64 {
+	if matchString == "" {
+		return 1
+	}
+	first, last := offset, offset
+	if offset < 0 {
+		first, last = l.Start, l.End-len(matchString)
+	}
+	e := l.Estimate()
+	for offset := first; offset <= last; offset++ {
+		shared, own := 1.0, 1.0
+		for i := range len(matchString) {
+			p := l.Probability(offset+i, matchString[i:i+1], ignoreCase)
+			if l.Shared(offset + i) {
+				shared *= p
+			} else {
+				own *= p
+			}
+		}
+		e.Add(shared, own)
+	}
+	return e.Keys()
+}
+
+// Estimate adds up the chances of matches at the offsets of a key, to
+// estimate the expected number of keys to test until one has a match.
+type Estimate struct {
+	keys int
+	// p is the expected number of matches in a candidate.
+	p float64
+	// miss is the log of the probability that keys candidates that share
+	// characters have no match.
+	miss float64
+}
+
+// Estimate returns an empty estimate for keys of the layout.
+func (l Layout) Estimate() Estimate {
+	return Estimate{keys: max(l.keys, 1)}
+}
+
+// Add adds a match with the probability shared*own, where shared is the
+// probability of its shared characters and own of the others.
+func (e *Estimate) Add(shared, own float64) {
+	e.p += shared * own
+	if e.keys == 1 {
+		return
+	}
+	// The own characters have keys chances while the shared ones stay the
+	// same.
+	ownMiss := float64(e.keys) * math.Log1p(-own)
+	if shared == 1 {
+		e.miss += ownMiss
+	} else {
+		e.miss += math.Log1p(shared * math.Expm1(ownMiss))
+	}
+}
+
+// Merge adds the matches of another estimate for the same layout.
+func (e *Estimate) Merge(o Estimate) {
+	e.p += o.p
+	e.miss += o.miss
+}
+
+// Keys returns the expected number of keys to test until one has a match.
+// With shared characters it is the number for independent candidates that
+// have the same chance of a match in keys candidates.
+func (e Estimate) Keys() float64 {
+	if e.keys == 1 {
+		return max(1/e.p, 1)
+	}
+	if e.miss == 0 {
+		return math.Inf(1)
+	}
+	return max(-1/math.Expm1(e.miss/float64(e.keys)), 1)
+}
+
+// Probability returns the probability that a random key has matchString at
+// offset.
+func (l Layout) Probability(offset int, matchString string, ignoreCase bool) float64 {
+	p := 1.0
+	for i := range len(matchString) {
+		symbols := l.Symbols(offset + i)
+		if symbols == "" {
+			return 0
+		}
+		matching := 0
+		for _, s := range []byte(symbols) {
+			if s == matchString[i] || ignoreCase && strings.EqualFold(string(s), matchString[i:i+1]) {
+				matching++
+			}
+		}
+		p *= float64(matching) / float64(len(symbols))
+	}
+	return p
+}
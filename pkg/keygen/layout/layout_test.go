@@ -0,0 +1,192 @@
+package layout
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// testLayout is a key type "t" with one fixed byte followed by two bytes that
+// depend on the key, except for the lowest bit.
+func testLayout() Layout {
+	return New("t", []byte{0x00, 0x00, 0x01}, []byte{0x00, 0xff, 0xfe})
+}
+
+func TestNew(t *testing.T) {
+	l := testLayout()
+	// "t AAAB": the first character is fixed, the second shares 2 bits with
+	// the fixed byte.
+	if l.Start != 3 || l.End != 6 {
+		t.Fatalf("Expected the key at 3 to 6, got %d to %d", l.Start, l.End)
+	}
+	for offset, want := range map[int]string{
+		2: "",
+		3: "ABCDEFGHIJKLMNOP",
+		4: Base64Alphabet,
+		5: "BDFHJLNPRTVXZbdfhjlnprtvxz13579/",
+		6: "",
+	} {
+		if got := l.Symbols(offset); got != want {
+			t.Errorf("Expected symbols %q at %d, got %q", want, offset, got)
+		}
+	}
+}
+
+func TestOrEd25519(t *testing.T) {
+	l := Layout{}.OrEd25519()
+	if l.Start != Ed25519Start || l.End != Ed25519Start+43 {
+		t.Errorf("Expected the ed25519 key at %d to %d, got %d to %d", Ed25519Start, Ed25519Start+43, l.Start, l.End)
+	}
+	if got := testLayout().OrEd25519(); got.Start != 3 || got.End != 6 {
+		t.Errorf("Expected a set layout to be kept, got %d to %d", got.Start, got.End)
+	}
+}
+
+func TestOffset(t *testing.T) {
+	l := testLayout()
+	for _, tt := range []struct {
+		anchor   Anchor
+		position int
+		n        int
+		want     int
+	}{
+		{AnchorAny, 0, 2, -1},
+		{AnchorStart, 0, 2, 3},
+		{AnchorStart, 1, 2, 4},
+		{AnchorEnd, 0, 2, 4},
+		{AnchorEnd, 1, 2, 3},
+	} {
+		got, err := l.Offset(tt.anchor, tt.position, tt.n)
+		if err != nil || got != tt.want {
+			t.Errorf("Expected offset %d for %s %d, got %d, %v", tt.want, tt.anchor, tt.position, got, err)
+		}
+	}
+	for _, tt := range []struct {
+		anchor   Anchor
+		position int
+		n        int
+	}{
+		{AnchorStart, 2, 2},
+		{AnchorEnd, 2, 2},
+		{AnchorStart, -1, 1},
+		{AnchorStart, 0, 4},
+		{"middle", 0, 1},
+	} {
+		if _, err := l.Offset(tt.anchor, tt.position, tt.n); err == nil {
+			t.Errorf("Expected an error for %d characters at %s %d", tt.n, tt.anchor, tt.position)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	l := testLayout()
+	if err := l.Check(3, "Pz/", false); err != nil {
+		t.Error(err)
+	}
+	if err := l.Check(3, "p", false); err == nil {
+		t.Error("Expected 'p' not to fit at the start")
+	}
+	if err := l.Check(3, "p", true); err != nil {
+		t.Error(err)
+	}
+	if err := l.Check(5, "A", false); err == nil || !strings.Contains(err.Error(), `try "B"`) {
+		t.Errorf("Expected 'A' not to fit at the end, got %v", err)
+	}
+}
+
+func TestDifficulty(t *testing.T) {
+	l := testLayout()
+	for _, tt := range []struct {
+		offset      int
+		matchString string
+		ignoreCase  bool
+		want        float64
+	}{
+		{3, "A", false, 16},
+		{3, "a", true, 16},
+		{4, "a", true, 32},
+		{4, "ab", false, 64 * 32},
+		{5, "A", false, math.Inf(1)},
+		{-1, "B", false, 1 / (1.0/16 + 1.0/64 + 1.0/32)},
+		{-1, "", false, 1},
+	} {
+		if got := l.Difficulty(tt.offset, tt.matchString, tt.ignoreCase); got != tt.want {
+			t.Errorf("Expected difficulty %v for %q at %d, got %v", tt.want, tt.matchString, tt.offset, got)
+		}
+	}
+}
+
+func TestShare(t *testing.T) {
+	// The middle byte only changes every 4 candidates.
+	l := testLayout().Share([]byte{0x00, 0xff, 0x00}, 4)
+	if !l.Shared(3) || !l.Shared(4) || l.Shared(5) {
+		t.Errorf("Expected the characters at 3 and 4 to be shared")
+	}
+	// perCandidate is the difficulty of independent candidates that match
+	// as often as 4 candidates do with probability q.
+	perCandidate := func(q float64) float64 { return 1 / (1 - math.Pow(1-q, 1.0/4)) }
+	for _, tt := range []struct {
+		offset      int
+		matchString string
+		want        float64
+	}{
+		// A shared character has one chance in 4 candidates.
+		{3, "A", perCandidate(1.0 / 16)},
+		// The own character has a chance in every candidate.
+		{5, "B", 32},
+		{4, "AB", perCandidate(1.0 / 64 * (1 - math.Pow(31.0/32, 4)))},
+		{5, "A", math.Inf(1)},
+	} {
+		if got := l.Difficulty(tt.offset, tt.matchString, false); math.IsInf(got, 1) != math.IsInf(tt.want, 1) || math.Abs(got-tt.want) > 1e-9*tt.want {
+			t.Errorf("Expected difficulty %v for %q at %d, got %v", tt.want, tt.matchString, tt.offset, got)
+		}
+	}
+}
+
+func TestFits(t *testing.T) {
+	l := testLayout()
+	for _, tt := range []struct {
+		from        int
+		matchString string
+		ignoreCase  bool
+	}{
+		{0, "t", false},
+		{0, "AA", false},
+		{3, "Pz/", false},
+		{3, "pz/", true},
+	} {
+		if err := l.Fits(tt.from, tt.matchString, tt.ignoreCase); err != nil {
+			t.Error(err)
+		}
+	}
+	for _, tt := range []struct {
+		matchString string
+		ignoreCase  bool
+		suggestion  string
+	}{
+		{"pzA", false, `try "PzB"`},
+		{"zzA", true, `try "PzB"`},
+		{"zzzz", false, "longer than the 3 characters"},
+	} {
+		err := l.Fits(3, tt.matchString, tt.ignoreCase)
+		if err == nil || !strings.Contains(err.Error(), tt.suggestion) {
+			t.Errorf("Expected an error containing %q for %q, got %v", tt.suggestion, tt.matchString, err)
+		}
+	}
+}
+
+func TestCheckBase64(t *testing.T) {
+	if err := CheckBase64("my+key/42"); err != nil {
+		t.Error(err)
+	}
+	for matchString, want := range map[string]string{
+		"my_key!":     `'_', '!' are not base64, try "my/key"`,
+		"hello-world": `try "hello+world"`,
+		"!!":          "only A-Z, a-z, 0-9, + and / are",
+		"":            "empty match string",
+	} {
+		if err := CheckBase64(matchString); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected an error containing %q for %q, got %v", want, matchString, err)
+		}
+	}
+}
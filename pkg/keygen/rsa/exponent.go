@@ -0,0 +1,201 @@
+package rsa
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"math/bits"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+const (
+	// The exponent is kept at three bytes with the top bit clear, so that
+	// stepping through it never changes the length of the public key blob.
+	// The lower bound is the usual 65537, x/crypto/ssh rejects exponents
+	// above 24 bits.
+	minExponent = 1<<16 + 1
+	maxExponent = 1<<23 - 1
+	exponentLen = 3
+
+	// exponentsPerPrimes is the number of exponents tried before a new
+	// modulus is generated. A new modulus changes the whole public key text,
+	// stepping the exponent only changes the few characters encoding it.
+	exponentsPerPrimes = 1 << 20
+
+	// Offsets in the public key blob: string "ssh-rsa", mpint e, mpint n.
+	exponentOffset = 4 + len(ssh.KeyAlgoRSA) + 4
+	// The base64 characters covering the exponent. The exponent starts on a
+	// 3 byte boundary, so they can be encoded separately.
+	exponentEncodedStart = exponentOffset / 3 * 4
+	exponentEncodedEnd   = (exponentOffset + exponentLen + 2) / 3 * 4
+)
+
+// exponentRsa searches for vanity keys by stepping through the public exponent
+// of a fixed modulus, like Scallion and Shallot do for onion addresses. Only
+// the exponent and its base64 encoding change between candidates, the private
+// exponent is derived once a match is found.
+//
+// Candidates depend on the ones before them, so with a deterministic reader a
+// key is only reproduced by replaying the attempts in order from the one that
+// drew its modulus, which Replay reports.
+type exponentRsa struct {
+	rand    io.Reader
+	bitSize int
+	format  Format
+
+	primes   *rsa.PrivateKey
+	pMinus1  *big.Int
+	qMinus1  *big.Int
+	e        int64
+	tries    int
+	bin      []byte
+	prefix   int
+	pubKey   []byte
+	finished *rsa.PrivateKey
+}
+
+func NewExponentSearch(rand io.Reader, bits int, format Format) *exponentRsa {
+	return &exponentRsa{
+		rand:    rand,
+		bitSize: bits,
+		format:  format,
+	}
+}
+
+func (s *exponentRsa) Generate() {
+	if s.primes == nil || s.tries >= exponentsPerPrimes {
+		s.newPrimes()
+	}
+	s.tries++
+	for {
+		s.e += 2
+		if s.e > maxExponent {
+			s.e = minExponent
+		}
+		if s.coprime() {
+			break
+		}
+	}
+	s.finished = nil
+
+	s.bin[exponentOffset] = byte(s.e >> 16)
+	s.bin[exponentOffset+1] = byte(s.e >> 8)
+	s.bin[exponentOffset+2] = byte(s.e)
+	base64.StdEncoding.Encode(
+		s.pubKey[s.prefix+exponentEncodedStart:s.prefix+exponentEncodedEnd],
+		s.bin[exponentEncodedStart/4*3:exponentEncodedEnd/4*3],
+	)
+}
+
+func (s *exponentRsa) newPrimes() {
+	primes, err := generateKey(s.rand, s.bitSize)
+	if err != nil {
+		panic("rsa: could not generate key: " + err.Error())
+	}
+	s.primes = primes
+	s.tries = 0
+	s.pMinus1 = new(big.Int).Sub(s.primes.Primes[0], big.NewInt(1))
+	s.qMinus1 = new(big.Int).Sub(s.primes.Primes[1], big.NewInt(1))
+	// Start at a random odd exponent.
+	var b [8]byte
+	if _, err := io.ReadFull(s.rand, b[:]); err != nil {
+		panic("rsa: could not read entropy: " + err.Error())
+	}
+	s.e = minExponent + 2*int64(binary.BigEndian.Uint64(b[:])%((maxExponent-minExponent)/2)) //nolint:gosec // The remainder fits in an int64.
+
+	publicKey, _ := ssh.NewPublicKey(&rsa.PublicKey{N: s.primes.N, E: minExponent})
+	s.bin = publicKey.Marshal()
+	s.prefix = len(ssh.KeyAlgoRSA) + 1
+	s.pubKey = make([]byte, s.prefix+base64.StdEncoding.EncodedLen(len(s.bin))+1)
+	copy(s.pubKey, ssh.KeyAlgoRSA+" ")
+	base64.StdEncoding.Encode(s.pubKey[s.prefix:], s.bin)
+	s.pubKey[len(s.pubKey)-1] = '\n'
+}
+
+// Replay returns the number of attempts since the modulus was drawn.
+func (s *exponentRsa) Replay() int {
+	return s.tries - 1
+}
+
+// coprime reports whether the exponent is invertible modulo λ(n), which holds
+// if it shares no factor with p-1 and q-1.
+func (s *exponentRsa) coprime() bool {
+	e := uint64(s.e) //nolint:gosec // e is always positive.
+	return gcd(e, mod(s.pMinus1, e)) == 1 && gcd(e, mod(s.qMinus1, e)) == 1
+}
+
+// mod returns x mod m without the allocations of big.Int.Mod.
+func mod(x *big.Int, m uint64) uint64 {
+	var r uint64
+	words := x.Bits()
+	for i := len(words) - 1; i >= 0; i-- {
+		if bits.UintSize == 32 {
+			r = (r<<32 | uint64(words[i])) % m
+		} else {
+			r = bits.Rem64(r, uint64(words[i]), m)
+		}
+	}
+	return r
+}
+
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// privateKey derives the private exponent for the current public exponent.
+func (s *exponentRsa) privateKey() (*rsa.PrivateKey, error) {
+	if s.finished != nil {
+		return s.finished, nil
+	}
+	gcdPQ := new(big.Int).GCD(nil, nil, s.pMinus1, s.qMinus1)
+	lambda := new(big.Int).Div(new(big.Int).Mul(s.pMinus1, s.qMinus1), gcdPQ)
+	d := new(big.Int).ModInverse(big.NewInt(s.e), lambda)
+	if d == nil {
+		return nil, errors.New("rsa: public exponent is not invertible")
+	}
+	key := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: s.primes.N, E: int(s.e)},
+		D:         d,
+		Primes:    []*big.Int{s.primes.Primes[0], s.primes.Primes[1]},
+	}
+	key.Precompute()
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+	s.finished = key
+	return key, nil
+}
+
+func (s *exponentRsa) SSHPubkey() []byte {
+	return s.pubKey
+}
+
+func (s *exponentRsa) Layout() layout.Layout {
+	return newLayout(s.bitSize, true)
+}
+
+func (s *exponentRsa) PrivateKey() crypto.Signer {
+	key, err := s.privateKey()
+	if err != nil {
+		return nil
+	}
+	return key
+}
+
+func (s *exponentRsa) SSHPrivkey() []byte {
+	key, err := s.privateKey()
+	if err != nil {
+		return nil
+	}
+	return marshalPrivkey(key, s.format)
+}
@@ -0,0 +1,88 @@
+package rsa
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"math"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestExponentSearch(t *testing.T) {
+	r := NewExponentSearch(rand.Reader, 2048, OpenSSH)
+
+	var firstPub []byte
+	var modulus string
+	for i := range 100 {
+		r.Generate()
+		if r.Replay() != i {
+			t.Errorf("Got replay %d after %d attempts with one modulus, want %d", r.Replay(), i+1, i)
+		}
+
+		pub := r.SSHPubkey()
+		parsed, _, _, _, err := ssh.ParseAuthorizedKey(pub)
+		if err != nil {
+			t.Fatalf("Failed to parse authorized key: %v", err)
+		}
+		rsaPub := parsed.(ssh.CryptoPublicKey).CryptoPublicKey().(*rsa.PublicKey)
+		if rsaPub.E < minExponent || rsaPub.E > maxExponent || rsaPub.E%2 == 0 {
+			t.Errorf("Exponent %d out of bounds", rsaPub.E)
+		}
+		if i == 0 {
+			firstPub = bytes.Clone(pub)
+			modulus = rsaPub.N.String()
+			continue
+		}
+		if rsaPub.N.String() != modulus {
+			t.Error("Expected the modulus to be reused")
+		}
+		if bytes.Equal(pub, firstPub) {
+			t.Error("Expected the public key to change")
+		}
+	}
+
+	signer, err := ssh.ParsePrivateKey(r.SSHPrivkey())
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey(r.SSHPubkey())
+	if err != nil {
+		t.Fatalf("Failed to parse authorized key: %v", err)
+	}
+	if !bytes.Equal(signer.PublicKey().Marshal(), parsed.Marshal()) {
+		t.Error("Private key does not match public key")
+	}
+
+	sig, err := signer.Sign(nil, []byte("data"))
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	if err := parsed.Verify([]byte("data"), sig); err != nil {
+		t.Errorf("Failed to verify signature: %v", err)
+	}
+}
+
+func TestExponentLayout(t *testing.T) {
+	l, full := newLayout(2048, true), newLayout(2048, false)
+	// The modulus only changes every exponentsPerPrimes candidates, so a
+	// match there takes about that many times longer.
+	offset := full.End - 5
+	want := full.Difficulty(offset, "abcd", false) * exponentsPerPrimes
+	if d := l.Difficulty(offset, "abcd", false); math.Abs(d-want) > want*1e-6 {
+		t.Errorf("Expected difficulty %v in the modulus, got %v", want, d)
+	}
+	// The exponent changes with every candidate.
+	if d := l.Difficulty(l.Start+1, "ab", false); d > 64*64*2 {
+		t.Errorf("Expected difficulty below %v in the exponent, got %v", 64*64*2, d)
+	}
+}
+
+func BenchmarkExponentSearchGenerate(b *testing.B) {
+	r := NewExponentSearch(rand.Reader, 2048, OpenSSH)
+	r.Generate()
+	for b.Loop() {
+		r.Generate()
+	}
+}
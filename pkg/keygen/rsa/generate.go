@@ -0,0 +1,85 @@
+package rsa
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// publicExponent is the exponent used by ssh-keygen and crypto/rsa.
+const publicExponent = 65537
+
+// generateKey returns a new RSA key. crypto/rsa ignores the reader since Go
+// 1.26, so keys from any other reader than crypto/rand are generated here to
+// make them reproducible from a deterministic reader.
+func generateKey(random io.Reader, bits int) (*rsa.PrivateKey, error) {
+	if random == rand.Reader {
+		return rsa.GenerateKey(random, bits)
+	}
+	if bits < 1024 {
+		return nil, errors.New("rsa: key size too small")
+	}
+
+	e := big.NewInt(publicExponent)
+	one := big.NewInt(1)
+	for {
+		p, err := randomPrime(random, bits-bits/2)
+		if err != nil {
+			return nil, err
+		}
+		q, err := randomPrime(random, bits/2)
+		if err != nil {
+			return nil, err
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+		n := new(big.Int).Mul(p, q)
+		if n.BitLen() != bits {
+			continue
+		}
+		pMinus1 := new(big.Int).Sub(p, one)
+		qMinus1 := new(big.Int).Sub(q, one)
+		phi := new(big.Int).Mul(pMinus1, qMinus1)
+		d := new(big.Int).ModInverse(e, phi)
+		if d == nil {
+			continue
+		}
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: publicExponent},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		key.Precompute()
+		if err := key.Validate(); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+}
+
+// randomPrime draws random odd numbers of the given size, with the two top
+// bits set so the product of two primes has the full size, until one is
+// prime. Like crypto/rand.Prime, but deterministic for a given reader.
+func randomPrime(random io.Reader, bits int) (*big.Int, error) {
+	b := make([]byte, (bits+7)/8)
+	excessBits := len(b)*8 - bits
+	p := new(big.Int)
+	for {
+		if _, err := io.ReadFull(random, b); err != nil {
+			return nil, err
+		}
+		b[0] &= 0xff >> excessBits
+		b[0] |= 0xc0 >> excessBits
+		if excessBits == 7 {
+			b[1] |= 0x80
+		}
+		b[len(b)-1] |= 1
+		p.SetBytes(b)
+		if p.ProbablyPrime(20) {
+			return p, nil
+		}
+	}
+}
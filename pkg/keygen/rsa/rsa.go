@@ -1,25 +1,47 @@
 package rsa
 
 import (
-	"crypto/rand"
+	"crypto"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"io"
+	"math/big"
+	"slices"
 
 	"golang.org/x/crypto/ssh"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519/edkey"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+// Format selects how SSHPrivkey writes the private key.
+type Format string
+
+const (
+	// OpenSSH writes an "OPENSSH PRIVATE KEY" block, like ssh-keygen does.
+	OpenSSH Format = "openssh"
+	// PKCS1 writes a legacy "RSA PRIVATE KEY" block.
+	PKCS1 Format = "pkcs1"
 )
 
 type localRsa struct {
+	rand       io.Reader
 	privateKey *rsa.PrivateKey
 	bitSize    int
+	format     Format
 }
 
-func New(bits int) *localRsa {
-	return &localRsa{bitSize: bits}
+func New(rand io.Reader, bits int, format Format) *localRsa {
+	return &localRsa{rand: rand, bitSize: bits, format: format}
 }
 
 func (s *localRsa) Generate() {
-	s.privateKey, _ = rsa.GenerateKey(rand.Reader, s.bitSize)
+	privateKey, err := generateKey(s.rand, s.bitSize)
+	if err != nil {
+		panic("rsa: could not generate key: " + err.Error())
+	}
+	s.privateKey = privateKey
 }
 
 func (s *localRsa) SSHPubkey() []byte {
@@ -27,12 +49,64 @@ func (s *localRsa) SSHPubkey() []byte {
 	return ssh.MarshalAuthorizedKey(publicKey)
 }
 
+func (s *localRsa) Layout() layout.Layout {
+	return newLayout(s.bitSize, false)
+}
+
+func (s *localRsa) PrivateKey() crypto.Signer {
+	return s.privateKey
+}
+
 func (s *localRsa) SSHPrivkey() []byte {
-	privDER := x509.MarshalPKCS1PrivateKey(s.privateKey)
-	privBlock := pem.Block{
-		Type:    "RSA PRIVATE KEY",
-		Headers: nil,
-		Bytes:   privDER,
+	return marshalPrivkey(s.privateKey, s.format)
+}
+
+// newLayout returns the layout of RSA public key lines. The modulus depends on
+// the key except for its top and bottom bits, which are always set. In
+// exponent search the exponent depends on the key too, it is always odd and
+// its top bit is clear, and the modulus is shared by exponentsPerPrimes
+// candidates.
+func newLayout(bits int, exponentSearch bool) layout.Layout {
+	n := new(big.Int).SetBit(big.NewInt(1), bits-1, 1)
+	publicKey, _ := ssh.NewPublicKey(&rsa.PublicKey{N: n, E: minExponent})
+	bin := publicKey.Marshal()
+	free := make([]byte, len(bin))
+	modulusLen := (bits + 7) / 8
+	start := len(bin) - modulusLen
+	for i := start; i < len(bin); i++ {
+		free[i] = 0xff
+	}
+	free[start] >>= modulusLen*8 - bits + 1
+	free[len(bin)-1] = 0xfe
+	if !exponentSearch {
+		return layout.New(ssh.KeyAlgoRSA, bin, free)
+	}
+	modulus := slices.Clone(free)
+	free[exponentOffset] = 0x7f
+	free[exponentOffset+1] = 0xff
+	free[exponentOffset+2] = 0xfe
+	return layout.New(ssh.KeyAlgoRSA, bin, free).Share(modulus, exponentsPerPrimes)
+}
+
+func marshalPrivkey(key *rsa.PrivateKey, format Format) []byte {
+	var privBlock pem.Block
+	switch format {
+	case PKCS1:
+		privBlock = pem.Block{
+			Type:    "RSA PRIVATE KEY",
+			Headers: nil,
+			Bytes:   x509.MarshalPKCS1PrivateKey(key),
+		}
+	default:
+		privDER, err := edkey.MarshalPrivateKey(key, edkey.Options{})
+		if err != nil {
+			return nil
+		}
+		privBlock = pem.Block{
+			Type:    "OPENSSH PRIVATE KEY",
+			Headers: nil,
+			Bytes:   privDER,
+		}
 	}
 	// Private key in PEM format
 	privatePEM := pem.EncodeToMemory(&privBlock)
@@ -1,32 +1,65 @@
 package rsa
 
 import (
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
 	"testing"
+	"testing/iotest"
 
 	"golang.org/x/crypto/ssh"
 )
 
 func TestRSA(t *testing.T) {
-	r := New(2048)
-	r.Generate()
+	for format, pemType := range map[Format]string{
+		OpenSSH: "OPENSSH PRIVATE KEY",
+		PKCS1:   "RSA PRIVATE KEY",
+	} {
+		t.Run(string(format), func(t *testing.T) {
+			r := New(rand.Reader, 2048, format)
+			r.Generate()
 
-	pub := r.SSHPubkey()
-	if len(pub) == 0 {
-		t.Error("SSHPubkey() returned empty result")
-	}
+			pub := r.SSHPubkey()
+			if len(pub) == 0 {
+				t.Error("SSHPubkey() returned empty result")
+			}
 
-	_, _, _, _, err := ssh.ParseAuthorizedKey(pub)
-	if err != nil {
-		t.Errorf("Failed to parse authorized key: %v", err)
-	}
+			_, _, _, _, err := ssh.ParseAuthorizedKey(pub)
+			if err != nil {
+				t.Errorf("Failed to parse authorized key: %v", err)
+			}
+
+			priv := r.SSHPrivkey()
+			if len(priv) == 0 {
+				t.Error("SSHPrivkey() returned empty result")
+			}
 
-	priv := r.SSHPrivkey()
-	if len(priv) == 0 {
-		t.Error("SSHPrivkey() returned empty result")
+			block, _ := pem.Decode(priv)
+			if block == nil || block.Type != pemType {
+				t.Errorf("Expected a %s PEM block", pemType)
+			}
+
+			_, err = ssh.ParseRawPrivateKey(priv)
+			if err != nil {
+				t.Errorf("Failed to parse private key: %v", err)
+			}
+		})
 	}
+}
 
-	_, err = ssh.ParseRawPrivateKey(priv)
-	if err != nil {
-		t.Errorf("Failed to parse private key: %v", err)
+func TestGenerateReadError(t *testing.T) {
+	r := iotest.ErrReader(errors.New("no entropy"))
+	for name, k := range map[string]interface{ Generate() }{
+		"primes":   New(r, 2048, OpenSSH),
+		"exponent": NewExponentSearch(r, 2048, OpenSSH),
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected Generate to panic without entropy")
+				}
+			}()
+			k.Generate()
+		})
 	}
 }
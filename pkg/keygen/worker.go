@@ -1,38 +1,114 @@
 package keygen
 
-import "context"
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"log/slog"
+	"sync/atomic"
+)
 
 type Worker struct {
 	results chan SSHKey
-	count   int64
+	count   atomic.Int64
+	// stream is the attempt whose stream the current candidate was derived
+	// from.
+	stream atomic.Int64
 
 	Matchfunc func(SSHKey) bool
-	Keyfunc   func() SSHKey
+	Keyfunc   Keygen
+	// Rand is the entropy source of the worker's keys, crypto/rand if nil.
+	Rand io.Reader
+	// ID identifies the worker in logs.
+	ID int
+	// StartAttempt is the number of attempts made before, when a search is
+	// resumed. With a deterministic Rand the worker continues the key stream
+	// where it left off.
+	StartAttempt int64
+	// Replay is the number of attempts before StartAttempt that are generated
+	// again without matching, so that a Replayer key continues the candidates
+	// it derived from an earlier attempt's stream.
+	Replay int64
+	// Continue keeps the worker searching with a new key after a result is
+	// sent, until ctx is done.
+	Continue bool
 }
 
 func (w *Worker) Run(ctx context.Context) {
-	k := w.Keyfunc()
+	r := w.Rand
+	if r == nil {
+		r = rand.Reader
+	}
+	ar, _ := r.(AttemptReader)
+	attempt := w.StartAttempt - w.Replay
+	w.count.Store(w.StartAttempt)
+	w.stream.Store(attempt)
 	for {
+		k := w.Keyfunc(r)
+		rp, _ := k.(Replayer)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+			if ar != nil {
+				ar.SetAttempt(uint64(attempt)) //nolint:gosec // Attempts are never negative.
+			}
+			k.Generate()
+			stream := attempt
+			if rp != nil {
+				stream -= int64(rp.Replay())
+			}
+			w.stream.Store(stream)
+			attempt++
+			if attempt <= w.StartAttempt {
+				// Replayed, matched before the search was resumed.
+				continue
+			}
+			w.count.Store(attempt)
+			if w.Matchfunc(k) {
+				// A result was found!
+				break
+			}
+		}
+		if ar != nil {
+			found := attempt - 1
+			if rp == nil || rp.Replay() == 0 {
+				// The seed, worker and attempt reproduce the key.
+				slog.Info("Found key in deterministic stream",
+					"worker", w.ID,
+					"attempt", found,
+				)
+			} else {
+				slog.Info("Found key in deterministic stream, generate from the replay attempt on to reproduce it",
+					"worker", w.ID,
+					"attempt", found,
+					"replay_from", found-int64(rp.Replay()),
+				)
+			}
+		}
 		select {
+		case w.results <- k:
 		case <-ctx.Done():
 			return
-		default:
 		}
-		w.count += 1
-		k.Generate()
-		if w.Matchfunc(k) {
-			// A result was found!
-			break
+		if !w.Continue {
+			return
 		}
-	}
-	select {
-	case w.results <- k:
-	case <-ctx.Done():
+		// The result is owned by the receiver now.
 	}
 }
 
 func (w *Worker) Count() int64 {
-	return w.count
+	return w.count.Load()
+}
+
+// Stream returns the attempt whose stream the current candidate was derived
+// from, at or before Count()-1. Resuming with Replay set to the attempts in
+// between continues the same candidates.
+func (w *Worker) Stream() int64 {
+	return w.stream.Load()
 }
 
 func (w *Worker) SetResultChan(results chan SSHKey) {
@@ -2,6 +2,8 @@ package keygen
 
 import (
 	"context"
+	"io"
+	"slices"
 	"testing"
 )
 
@@ -25,7 +27,7 @@ func TestWorker(t *testing.T) {
 		Matchfunc: func(k SSHKey) bool {
 			return k.(*mockWorkerKey).count == k.(*mockWorkerKey).match
 		},
-		Keyfunc: func() SSHKey {
+		Keyfunc: func(io.Reader) SSHKey {
 			return key
 		},
 	}
@@ -46,3 +48,133 @@ func TestWorker(t *testing.T) {
 		t.Errorf("Expected key generate count 5, got %d", res.(*mockWorkerKey).count)
 	}
 }
+
+type mockAttemptReader struct {
+	attempts []uint64
+}
+
+func (m *mockAttemptReader) Read(p []byte) (int, error) { return len(p), nil }
+func (m *mockAttemptReader) SetAttempt(attempt uint64) {
+	m.attempts = append(m.attempts, attempt)
+}
+
+func TestWorkerSetsAttempt(t *testing.T) {
+	results := make(chan SSHKey, 1)
+	r := &mockAttemptReader{}
+	var keyRand io.Reader
+
+	w := &Worker{
+		Matchfunc: func(k SSHKey) bool {
+			return k.(*mockWorkerKey).count == 3
+		},
+		Keyfunc: func(rand io.Reader) SSHKey {
+			keyRand = rand
+			return &mockWorkerKey{}
+		},
+		Rand: r,
+	}
+	w.SetResultChan(results)
+	w.Run(context.Background())
+	<-results
+
+	if keyRand != r {
+		t.Error("Key was not created with the worker's reader")
+	}
+	if !slices.Equal(r.attempts, []uint64{0, 1, 2}) {
+		t.Errorf("Expected attempts [0 1 2], got %v", r.attempts)
+	}
+}
+
+func TestWorkerStartAttempt(t *testing.T) {
+	results := make(chan SSHKey, 1)
+	r := &mockAttemptReader{}
+
+	w := &Worker{
+		Matchfunc: func(k SSHKey) bool {
+			return k.(*mockWorkerKey).count == 2
+		},
+		Keyfunc: func(io.Reader) SSHKey {
+			return &mockWorkerKey{}
+		},
+		Rand:         r,
+		StartAttempt: 100,
+	}
+	w.SetResultChan(results)
+	w.Run(context.Background())
+	<-results
+
+	if !slices.Equal(r.attempts, []uint64{100, 101}) {
+		t.Errorf("Expected attempts [100 101], got %v", r.attempts)
+	}
+	if w.Count() != 102 {
+		t.Errorf("Expected count 102, got %d", w.Count())
+	}
+}
+
+func TestWorkerReplay(t *testing.T) {
+	results := make(chan SSHKey, 1)
+	r := &mockAttemptReader{}
+	var matched []int
+
+	w := &Worker{
+		Matchfunc: func(k SSHKey) bool {
+			matched = append(matched, k.(*mockWorkerKey).count)
+			return k.(*mockWorkerKey).count == 4
+		},
+		Keyfunc: func(io.Reader) SSHKey {
+			return &mockWorkerKey{}
+		},
+		Rand:         r,
+		StartAttempt: 100,
+		Replay:       2,
+	}
+	w.SetResultChan(results)
+	w.Run(context.Background())
+	<-results
+
+	// The replayed attempts are generated again, but not matched.
+	if !slices.Equal(r.attempts, []uint64{98, 99, 100, 101}) {
+		t.Errorf("Expected attempts [98 99 100 101], got %v", r.attempts)
+	}
+	if !slices.Equal(matched, []int{3, 4}) {
+		t.Errorf("Expected keys 3 and 4 to be matched, got %v", matched)
+	}
+	if w.Count() != 102 {
+		t.Errorf("Expected count 102, got %d", w.Count())
+	}
+	if w.Stream() != 101 {
+		t.Errorf("Expected stream 101, got %d", w.Stream())
+	}
+}
+
+func TestWorkerContinue(t *testing.T) {
+	results := make(chan SSHKey)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := &Worker{
+		Matchfunc: func(k SSHKey) bool {
+			return k.(*mockWorkerKey).count == 2
+		},
+		Keyfunc: func(io.Reader) SSHKey {
+			return &mockWorkerKey{}
+		},
+		Continue: true,
+	}
+	w.SetResultChan(results)
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	first, second := <-results, <-results
+	if first == second {
+		t.Error("Expected a new key after a result")
+	}
+	cancel()
+	<-done
+	if w.Count() < 4 {
+		t.Errorf("Expected at least 4 attempts, got %d", w.Count())
+	}
+}
@@ -0,0 +1,224 @@
+package bitmask
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+// maxChunkLetters limits the combinations of cases of a chunk to 16.
+const maxChunkLetters = 4
+
+// bitmaskMatcher matches the binary public key blob instead of its base64
+// text. Every character of the match string fixes 6 bits of the blob at the
+// offset it has to be at, so the match string turns into a mask and the value
+// the masked blob has to have. Keys that expose their blob skip the base64
+// encoding until a key matches, others are decoded.
+//
+// The upper and lower case of a letter have unrelated bits, so with
+// ignoreCase the match string is split into chunks of up to maxChunkLetters
+// letters. A chunk matches if the blob has any of the values of its
+// combinations of cases.
+//
+// The bit offsets come from the key layout, so the matcher only matches after
+// SetAnchor.
+type bitmaskMatcher struct {
+	ignoreCase  bool
+	matchString string
+	keyLayout   layout.Layout
+	// offset is where the match string has to be, -1 for anywhere.
+	offset int
+	// placements are the offsets the match string can be at.
+	placements []placement
+}
+
+// placement is the match string at an offset, it matches if all chunks do.
+type placement struct {
+	chunks []chunk
+}
+
+// chunk is a part of the match string. All its values have the same mask, from
+// the byte start of the blob.
+type chunk struct {
+	start  int
+	mask   []byte
+	values [][]byte
+}
+
+func New() *bitmaskMatcher {
+	return &bitmaskMatcher{offset: -1}
+}
+
+// NewIgnoreCase returns a matcher that matches letters of either case.
+func NewIgnoreCase() *bitmaskMatcher {
+	return &bitmaskMatcher{ignoreCase: true, offset: -1}
+}
+
+func (m *bitmaskMatcher) SetMatchString(matchString string) error {
+	m.matchString = matchString
+	m.offset, m.placements = -1, nil
+	return layout.CheckBase64(matchString)
+}
+
+func (m *bitmaskMatcher) SetAnchor(l layout.Layout, anchor layout.Anchor, position int) error {
+	offset, err := l.Offset(anchor, position, len(m.matchString))
+	if err != nil {
+		return err
+	}
+	m.keyLayout, m.offset, m.placements = l, offset, nil
+	if offset >= 0 {
+		if err := l.Check(offset, m.matchString, m.ignoreCase); err != nil {
+			return err
+		}
+		m.placements = append(m.placements, m.place(offset-l.Prefix))
+		return nil
+	}
+	for offset := l.Start; offset+len(m.matchString) <= l.End; offset++ {
+		// Offsets where a character can never be are left out.
+		if l.Check(offset, m.matchString, m.ignoreCase) == nil {
+			m.placements = append(m.placements, m.place(offset-l.Prefix))
+		}
+	}
+	if len(m.placements) == 0 {
+		if err := l.Fits(l.Start, m.matchString, m.ignoreCase); err != nil {
+			return err
+		}
+		return fmt.Errorf("%q fits nowhere in the key", m.matchString)
+	}
+	return nil
+}
+
+// place returns the masks and values of the match string at the index of the
+// base64 blob.
+func (m *bitmaskMatcher) place(index int) placement {
+	var p placement
+	from := 0
+	for from < len(m.matchString) {
+		to, letters := from, 0
+		for to < len(m.matchString) {
+			if m.ignoreCase && isLetter(m.matchString[to]) {
+				if letters == maxChunkLetters {
+					break
+				}
+				letters++
+			}
+			to++
+		}
+		p.chunks = append(p.chunks, m.chunk(index+from, m.matchString[from:to]))
+		from = to
+	}
+	return p
+}
+
+// chunk returns the mask and values of s at the index of the base64 blob.
+func (m *bitmaskMatcher) chunk(index int, s string) chunk {
+	firstBit := index * 6
+	lastBit := firstBit + len(s)*6
+	c := chunk{
+		start: firstBit / 8,
+		mask:  make([]byte, (lastBit+7)/8-firstBit/8),
+	}
+	bit := firstBit - c.start*8
+	for i := range s {
+		setSextet(c.mask, bit+i*6, 0x3f)
+	}
+	c.values = [][]byte{make([]byte, len(c.mask))}
+	for i := range len(s) {
+		symbols := s[i : i+1]
+		if m.ignoreCase && isLetter(s[i]) {
+			symbols = strings.ToUpper(symbols) + strings.ToLower(symbols)
+		}
+		var values [][]byte
+		for _, v := range c.values {
+			for j := range len(symbols) {
+				value := bytes.Clone(v)
+				setSextet(value, bit+i*6, byte(strings.IndexByte(layout.Base64Alphabet, symbols[j]))) //nolint:gosec // The index of a base64 symbol fits.
+				values = append(values, value)
+			}
+		}
+		c.values = values
+	}
+	return c
+}
+
+// setSextet sets the 6 bits from bit of b, counted from the most significant
+// bit of b[0], to v.
+func setSextet(b []byte, bit int, v byte) {
+	w := uint16(v) << (10 - bit%8)
+	b[bit/8] |= byte(w >> 8)
+	if bit/8+1 < len(b) {
+		b[bit/8+1] |= byte(w)
+	}
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func (m *bitmaskMatcher) Match(s keygen.SSHKey) bool {
+	var buf [layout.MaxBlobLen]byte
+	var blob []byte
+	if b, ok := s.(keygen.Blober); ok {
+		blob = b.Blob()
+	} else if blob, ok = layout.DecodeBlob(s.SSHPubkey(), buf[:]); !ok {
+		return false
+	}
+	for _, p := range m.placements {
+		if p.match(blob) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p placement) match(blob []byte) bool {
+	for _, c := range p.chunks {
+		if !c.match(blob) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c chunk) match(blob []byte) bool {
+	if c.start >= len(blob) {
+		return false
+	}
+	b := blob[c.start:min(c.start+len(c.mask), len(blob))]
+	for _, v := range c.values {
+		if matchValue(b, c.mask, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchValue reports if b masked with mask is v. The bits past the end of b
+// are padding, which is zero.
+func matchValue(b, mask, v []byte) bool {
+	for i := range v {
+		if i >= len(b) {
+			if v[i] != 0 {
+				return false
+			}
+			continue
+		}
+		if b[i]&mask[i] != v[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Difficulty returns the expected number of keys to test for a match, from
+// the key layout.
+func (m *bitmaskMatcher) Difficulty() float64 {
+	if len(m.placements) == 0 {
+		return math.Inf(1)
+	}
+	return m.keyLayout.Difficulty(m.offset, m.matchString, m.ignoreCase)
+}
@@ -0,0 +1,142 @@
+package bitmask
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"io"
+	mrand "math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ecdsa"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+type mockSSHKey struct {
+	pubkey []byte
+}
+
+func (m *mockSSHKey) SSHPubkey() []byte {
+	return m.pubkey
+}
+
+func (m *mockSSHKey) SSHPrivkey() []byte {
+	return nil
+}
+
+func (m *mockSSHKey) Generate() {}
+
+func TestBitmaskMatcher(t *testing.T) {
+	l := ed25519.New(rand.Reader).Layout()
+	// The key starts at index 37 and ends at index 80. The key is not a
+	// Blober, so it is decoded.
+	pubkey := &mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMyTeamabcdefghijklmnopqrstuvwxyz01234567x+g\n")}
+	for _, tc := range []struct {
+		matchString string
+		ignoreCase  bool
+		anchor      layout.Anchor
+		position    int
+		match       bool
+	}{
+		{"MyTeam", false, layout.AnchorStart, 0, true},
+		{"Myteam", false, layout.AnchorStart, 0, false},
+		{"myteam", true, layout.AnchorStart, 0, true},
+		{"yTeam", false, layout.AnchorStart, 1, true},
+		{"yTeam", false, layout.AnchorStart, 2, false},
+		{"x+g", false, layout.AnchorEnd, 0, true},
+		{"X+G", true, layout.AnchorEnd, 0, true},
+		{"7x+", false, layout.AnchorEnd, 1, true},
+		{"abcdefghijklmnopqrstuvwxyz", false, layout.AnchorAny, 0, true},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZ", true, layout.AnchorAny, 0, true},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZ", false, layout.AnchorAny, 0, false},
+		{"zyx", true, layout.AnchorAny, 0, false},
+	} {
+		m := New()
+		if tc.ignoreCase {
+			m = NewIgnoreCase()
+		}
+		if err := m.SetMatchString(tc.matchString); err != nil {
+			t.Fatalf("%s: %v", tc.matchString, err)
+		}
+		if err := m.SetAnchor(l, tc.anchor, tc.position); err != nil {
+			t.Fatalf("%s: %v", tc.matchString, err)
+		}
+		if m.Match(pubkey) != tc.match {
+			t.Errorf("%s %s %d ignorecase=%v: expected match=%v", tc.matchString, tc.anchor, tc.position, tc.ignoreCase, tc.match)
+		}
+	}
+}
+
+func TestBitmaskErrors(t *testing.T) {
+	l := ed25519.New(rand.Reader).Layout()
+	m := New()
+	if err := m.SetMatchString("my_key"); err == nil {
+		t.Error("Expected an error for a character that is not base64")
+	}
+	// The first character of an ed25519 key is one of A-P.
+	if err := m.SetMatchString("z"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAnchor(l, layout.AnchorStart, 0); err == nil {
+		t.Error("Expected an error for an impossible anchored match string")
+	}
+	if m.Match(&mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIz\n")}) {
+		t.Error("Expected no match without a placement")
+	}
+}
+
+// TestBitmaskRandom compares the bitmask matchers with a search of the base64
+// text, for random keys and match strings.
+func TestBitmaskRandom(t *testing.T) {
+	keygens := map[string]keygen.Keygen{
+		"ed25519":    func(r io.Reader) keygen.SSHKey { return ed25519.New(r) },
+		"ecdsa-p256": func(r io.Reader) keygen.SSHKey { return ecdsa.New(r, elliptic.P256()) },
+	}
+	r := mrand.New(mrand.NewPCG(1, 2)) //nolint:gosec // Reproducible test input.
+	for name, kg := range keygens {
+		k := kg(rand.Reader)
+		l := k.(keygen.Layouter).Layout()
+		for range 500 {
+			k.Generate()
+			pub := string(k.SSHPubkey())
+			n := 1 + r.IntN(3)
+			matchString := pub[l.Start+r.IntN(l.End-l.Start-n+1):][:n]
+			if r.IntN(2) == 0 {
+				matchString = strings.ToUpper(matchString)
+			}
+			anchor := []layout.Anchor{layout.AnchorAny, layout.AnchorStart, layout.AnchorEnd}[r.IntN(3)]
+
+			for _, ignoreCase := range []bool{false, true} {
+				m := New()
+				if ignoreCase {
+					m = NewIgnoreCase()
+				}
+				if err := m.SetMatchString(matchString); err != nil {
+					t.Fatal(err)
+				}
+				key, s := pub[l.Start:l.End], matchString
+				if ignoreCase {
+					key, s = strings.ToLower(key), strings.ToLower(s)
+				}
+				want := strings.Contains(key, s)
+				switch anchor {
+				case layout.AnchorStart:
+					want = strings.HasPrefix(key, s)
+				case layout.AnchorEnd:
+					want = strings.HasSuffix(key, s)
+				}
+				if err := m.SetAnchor(l, anchor, 0); err != nil {
+					if want {
+						t.Errorf("%s %q %s ignorecase=%v: %v", name, matchString, anchor, ignoreCase, err)
+					}
+					continue
+				}
+				if m.Match(k) != want {
+					t.Errorf("%s %q %s ignorecase=%v: expected match=%v for %s", name, matchString, anchor, ignoreCase, want, pub)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package dictionary
+
+import (
+	"bytes"
+	"fmt"
+	"math/bits"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+// DefaultMinLength is the minimum number of letters of a word if none is set.
+const DefaultMinLength = 4
+
+// symbols are the base64 symbols after case folding, in byte order so that
+// sorted words list the children of every trie node in symbol order.
+const symbols = "+/0123456789abcdefghijklmnopqrstuvwxyz"
+
+// symbolIndex maps every byte to its index in symbols, letters of either case
+// to the same index, and other bytes to -1.
+var symbolIndex = func() (index [256]int8) {
+	for i := range index {
+		index[i] = -1
+	}
+	for i, c := range []byte(symbols) {
+		index[c] = int8(i) //nolint:gosec // There are 38 symbols.
+		if c >= 'a' && c <= 'z' {
+			index[c-'a'+'A'] = int8(i) //nolint:gosec // There are 38 symbols.
+		}
+	}
+	return index
+}()
+
+// dictionaryMatcher matches keys that contain any word of a wordlist with at
+// least minLength letters, in either case. The match string is the wordlist,
+// one word per line. Words with characters that are not in base64, like
+// apostrophes, can never match and are skipped.
+//
+// The words are stored in a trie where every node has a bitmask of its
+// children, and the children of a node are stored next to each other. A
+// wordlist of hundreds of thousands of words takes a few megabytes.
+type dictionaryMatcher struct {
+	minLength int
+	// from and to are the part of the public key that depends on the key,
+	// to is -1 if the key layout is not known.
+	from, to int
+	// start and end are where a word has to start or end, -1 for anywhere.
+	start, end int
+	keyLayout  layout.Layout
+	// difficulty is estimated on the first call after the words or the
+	// anchor change, it takes too long for every call. It is 0 until then.
+	mu         sync.Mutex
+	difficulty float64
+
+	// children has a bit for every symbol that has a child node. The
+	// children of node n are the nodes from first[n], in symbol order.
+	children []uint64
+	first    []int32
+	// word is 1 + the index of the word that ends at the node, or 0.
+	word    []int32
+	words   []string
+	longest int
+}
+
+func New() *dictionaryMatcher {
+	m := &dictionaryMatcher{minLength: DefaultMinLength, to: -1, start: -1, end: -1}
+	m.build(nil)
+	return m
+}
+
+// SetMinLength sets the minimum number of letters of the words of the next
+// wordlist. It is at least 1, an empty word would match every key.
+func (m *dictionaryMatcher) SetMinLength(n int) {
+	m.minLength = max(n, 1)
+}
+
+func (m *dictionaryMatcher) SetMatchString(wordlist string) error {
+	m.from, m.to, m.start, m.end = 0, -1, -1, -1
+	m.keyLayout = layout.Layout{}
+	var words []string
+	for line := range strings.Lines(wordlist) {
+		word := strings.ToLower(strings.TrimSpace(line))
+		if len(word) < m.minLength || !isBase64(word) {
+			continue
+		}
+		words = append(words, word)
+	}
+	slices.Sort(words)
+	words = slices.Compact(words)
+	m.build(words)
+	m.difficulty = 0
+	if len(words) == 0 {
+		return fmt.Errorf("no words of at least %d letters that can be in base64", m.minLength)
+	}
+	return nil
+}
+
+func isBase64(word string) bool {
+	for _, c := range []byte(word) {
+		if symbolIndex[c] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// build stores the sorted words in the trie, one level at a time so that the
+// children of every node are next to each other.
+func (m *dictionaryMatcher) build(words []string) {
+	m.words, m.longest = words, 0
+	m.children, m.first, m.word = []uint64{0}, []int32{0}, []int32{0}
+
+	// A span is the words below a node, the words of its subtree are next to
+	// each other since they are sorted.
+	type span struct {
+		node   int
+		depth  int
+		lo, hi int
+	}
+	level := []span{{hi: len(words)}}
+	for len(level) > 0 {
+		var next []span
+		for _, s := range level {
+			lo := s.lo
+			// A word that ends at the node sorts first in its span.
+			if lo < s.hi && len(words[lo]) == s.depth {
+				m.word[s.node] = int32(lo + 1) //nolint:gosec // The number of words fits.
+				m.longest = s.depth
+				lo++
+			}
+			m.first[s.node] = int32(len(m.first)) //nolint:gosec // The number of nodes fits.
+			for lo < s.hi {
+				c := words[lo][s.depth]
+				hi := lo + 1
+				for hi < s.hi && words[hi][s.depth] == c {
+					hi++
+				}
+				m.children[s.node] |= 1 << symbolIndex[c]
+				next = append(next, span{node: len(m.first), depth: s.depth + 1, lo: lo, hi: hi})
+				m.children = append(m.children, 0)
+				m.first = append(m.first, 0)
+				m.word = append(m.word, 0)
+				lo = hi
+			}
+		}
+		level = next
+	}
+}
+
+// SetAnchor limits the search to the part of the public key that depends on
+// the key. With the start anchor a word has to start at the position, with the
+// end anchor it has to end there.
+func (m *dictionaryMatcher) SetAnchor(l layout.Layout, anchor layout.Anchor, position int) error {
+	offset, err := l.Offset(anchor, position, 0)
+	if err != nil {
+		return err
+	}
+	m.from, m.to, m.start, m.end = l.Start, l.End, -1, -1
+	m.keyLayout = l
+	switch anchor {
+	case layout.AnchorStart:
+		m.start = offset
+	case layout.AnchorEnd:
+		m.end = offset
+	}
+	m.difficulty = 0
+	return nil
+}
+
+// Difficulty returns the expected number of keys to test for a match.
+func (m *dictionaryMatcher) Difficulty() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.difficulty == 0 {
+		m.difficulty = m.estimate()
+	}
+	return m.difficulty
+}
+
+// estimate adds up the expected matches of every word in a key, and returns
+// the expected number of keys until one has any. Overlapping words, like
+// "horse" and "horses", are counted as independent, which makes the estimate
+// a little low. Without a key layout it is estimated for ed25519 keys.
+//
+// The trie is walked from every offset a word can start at, so prefixes that
+// words share are only counted once. Offsets followed by the same symbol
+// probabilities as an earlier offset, like most of an RSA modulus, reuse its
+// matches.
+func (m *dictionaryMatcher) estimate() float64 {
+	l := m.keyLayout.OrEd25519()
+	from, to := l.Start, l.End
+	// p holds the probability of every symbol at every offset of the key,
+	// ids number its distinct columns.
+	type column struct {
+		p      [len(symbols)]float64
+		shared bool
+	}
+	p := make([][len(symbols)]float64, to-from)
+	shared := make([]bool, len(p))
+	columns := map[column]rune{}
+	ids := make([]rune, len(p))
+	for i := range p {
+		for j, c := range []byte(symbols) {
+			p[i][j] = l.Probability(from+i, string(c), true)
+		}
+		shared[i] = l.Shared(from + i)
+		c := column{p[i], shared[i]}
+		if _, ok := columns[c]; !ok {
+			columns[c] = rune(len(columns))
+		}
+		ids[i] = columns[c]
+	}
+
+	first, last := 0, len(p)-1
+	switch {
+	case m.start >= 0:
+		first, last = m.start-from, m.start-from
+	case m.end >= 0:
+		first, last = m.end-from-m.longest, m.end-from-1
+	}
+	e := l.Estimate()
+	windows := map[string]layout.Estimate{}
+	for offset := max(first, 0); offset <= min(last, len(p)-1); offset++ {
+		// Words end before the end of the key, or at the end anchor.
+		n := min(len(p)-offset, m.longest)
+		if m.end >= 0 {
+			n = m.end - from - offset
+		}
+		window := string(ids[offset : offset+n])
+		w, ok := windows[window]
+		if !ok {
+			w = l.Estimate()
+			m.walk(&w, p[offset:offset+n], shared[offset:offset+n], m.end >= 0)
+			windows[window] = w
+		}
+		e.Merge(w)
+	}
+	return e.Keys()
+}
+
+// walk adds the matches of the words that start at the first of the symbol
+// probabilities p to e, the words that end at the last one if exact.
+func (m *dictionaryMatcher) walk(e *layout.Estimate, p [][len(symbols)]float64, shared []bool, exact bool) {
+	type step struct {
+		node, depth int
+		shared, own float64
+	}
+	stack := []step{{shared: 1, own: 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if m.word[s.node] != 0 && (!exact || s.depth == len(p)) {
+			e.Add(s.shared, s.own)
+		}
+		if s.depth == len(p) {
+			continue
+		}
+		child := int(m.first[s.node])
+		for children := m.children[s.node]; children != 0; children &= children - 1 {
+			next := step{node: child, depth: s.depth + 1, shared: s.shared, own: s.own}
+			child++
+			q := p[s.depth][bits.TrailingZeros64(children)]
+			if q == 0 {
+				continue
+			}
+			if shared[s.depth] {
+				next.shared *= q
+			} else {
+				next.own *= q
+			}
+			stack = append(stack, next)
+		}
+	}
+}
+
+func (m *dictionaryMatcher) Match(s keygen.SSHKey) bool {
+	_, _, ok := m.find(s.SSHPubkey())
+	return ok
+}
+
+// Locate returns the word that matched the key, and its offset in the public
+// key.
+func (m *dictionaryMatcher) Locate(s keygen.SSHKey) (string, int, bool) {
+	return m.find(s.SSHPubkey())
+}
+
+// find returns the longest word at the first offset of pubK where one starts.
+func (m *dictionaryMatcher) find(pubK []byte) (string, int, bool) {
+	from, to := m.from, m.to
+	if to < 0 {
+		// Search the base64 part like the other matchers.
+		from = bytes.IndexByte(pubK, ' ') + 1
+		to = bytes.IndexAny(pubK[from:], " \n")
+		if to < 0 {
+			to = len(pubK)
+		} else {
+			to += from
+		}
+	}
+	to = min(to, len(pubK))
+	switch {
+	case m.start >= 0:
+		if word, n := m.prefix(pubK[min(m.start, to):to]); n > 0 {
+			return m.words[word], m.start, true
+		}
+	case m.end >= 0:
+		if m.end > to {
+			break
+		}
+		for i := max(from, m.end-m.longest); i < m.end; i++ {
+			if word, n := m.prefix(pubK[i:m.end]); n == m.end-i {
+				return m.words[word], i, true
+			}
+		}
+	default:
+		for i := from; i < to; i++ {
+			if word, n := m.prefix(pubK[i:to]); n > 0 {
+				return m.words[word], i, true
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// prefix returns the index and length of the longest word that b starts with,
+// the length is 0 if there is none.
+func (m *dictionaryMatcher) prefix(b []byte) (int, int) {
+	word, n := 0, 0
+	node := 0
+	for i, c := range b {
+		s := symbolIndex[c]
+		if s < 0 {
+			break
+		}
+		bit := uint64(1) << s
+		children := m.children[node]
+		if children&bit == 0 {
+			break
+		}
+		node = int(m.first[node]) + bits.OnesCount64(children&(bit-1))
+		if w := m.word[node]; w != 0 {
+			word, n = int(w-1), i+1
+		}
+	}
+	return word, n
+}
@@ -0,0 +1,270 @@
+package dictionary
+
+import (
+	"crypto/rand"
+	"math"
+	mrand "math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/rsa"
+)
+
+type mockSSHKey struct {
+	pubkey []byte
+}
+
+func (m *mockSSHKey) SSHPubkey() []byte {
+	return m.pubkey
+}
+
+func (m *mockSSHKey) SSHPrivkey() []byte {
+	return nil
+}
+
+func (m *mockSSHKey) Generate() {}
+
+func TestDictionaryMatcher(t *testing.T) {
+	m := New()
+	if err := m.SetMatchString("cat\nhorse\nHorses\n  house \ndon't\nhorse\nb4by\n"); err != nil {
+		t.Fatal(err)
+	}
+	// Short words, words that can not be in base64 and duplicates are
+	// skipped.
+	if got := strings.Join(m.words, ","); got != "b4by,horse,horses,house" {
+		t.Errorf("Unexpected words %s", got)
+	}
+
+	testCases := []struct {
+		pubkey string
+		word   string
+		offset int
+	}{
+		{"ssh-ed25519 AAAAxhorsex\n", "horse", 17},
+		// The longest word at the first offset wins.
+		{"ssh-ed25519 AAAAHoRsEShouse\n", "horses", 16},
+		{"ssh-ed25519 AAAAhohousehorse\n", "house", 18},
+		{"ssh-ed25519 AAAAxB4BYx\n", "b4by", 17},
+		{"ssh-ed25519 AAAAcatx\n", "", 0},
+		{"ssh-ed25519 AAAAhors\n", "", 0},
+		// Only the base64 part is searched.
+		{"house-ed25519 AAAA\n", "", 0},
+		{"ssh-ed25519 AAAA house\n", "", 0},
+	}
+	for _, tc := range testCases {
+		key := &mockSSHKey{pubkey: []byte(tc.pubkey)}
+		if m.Match(key) != (tc.word != "") {
+			t.Errorf("Expected match=%v for pubkey %q", tc.word != "", tc.pubkey)
+		}
+		word, offset, ok := m.Locate(key)
+		if ok != (tc.word != "") || word != tc.word || offset != tc.offset {
+			t.Errorf("Expected %q at %d for pubkey %q, got %q at %d", tc.word, tc.offset, tc.pubkey, word, offset)
+		}
+	}
+}
+
+func TestMinLength(t *testing.T) {
+	m := New()
+	m.SetMinLength(3)
+	if err := m.SetMatchString("cat\nhorse"); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Match(&mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAcat\n")}) {
+		t.Error("Expected cat to match with a minimum length of 3")
+	}
+	m.SetMinLength(6)
+	if err := m.SetMatchString("cat\nhorse"); err == nil {
+		t.Error("Expected an error without words of 6 letters")
+	}
+	// An empty line is never a word, it would match every key.
+	m.SetMinLength(0)
+	if err := m.SetMatchString("\n"); err == nil {
+		t.Error("Expected an error for an empty word")
+	}
+	if err := m.SetMatchString("\nhorse"); err != nil {
+		t.Fatal(err)
+	}
+	if m.Match(&mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAcat\n")}) {
+		t.Error("Expected the empty line not to match")
+	}
+}
+
+func TestAnchor(t *testing.T) {
+	l := ed25519.New(rand.Reader).Layout()
+	// The key starts at index 37 and ends at index 80.
+	pubkey := &mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMhouseAbcdefghijklmnopqrstuvwxyz012345horse\n")}
+	for _, tc := range []struct {
+		anchor   layout.Anchor
+		position int
+		word     string
+		offset   int
+	}{
+		{layout.AnchorAny, 0, "house", 38},
+		{layout.AnchorStart, 1, "house", 38},
+		{layout.AnchorStart, 0, "", 0},
+		{layout.AnchorEnd, 0, "horse", 75},
+		{layout.AnchorEnd, 1, "", 0},
+	} {
+		m := New()
+		if err := m.SetMatchString("house\nhorse\nzzzzzzzz"); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.SetAnchor(l, tc.anchor, tc.position); err != nil {
+			t.Fatal(err)
+		}
+		word, offset, ok := m.Locate(pubkey)
+		if ok != (tc.word != "") || word != tc.word || offset != tc.offset {
+			t.Errorf("%s %d: expected %q at %d, got %q at %d", tc.anchor, tc.position, tc.word, tc.offset, word, offset)
+		}
+	}
+
+	// Words in the fixed header of the key type never match.
+	m := New()
+	if err := m.SetMatchString("aaaac3nz"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAnchor(l, layout.AnchorAny, 0); err != nil {
+		t.Fatal(err)
+	}
+	if m.Match(pubkey) {
+		t.Error("Expected no match in the header")
+	}
+}
+
+func TestDifficulty(t *testing.T) {
+	l := ed25519.New(rand.Reader).Layout()
+	m := New()
+	if d := m.Difficulty(); !math.IsInf(d, 1) {
+		t.Errorf("Expected no words to never match, got %v", d)
+	}
+	if err := m.SetMatchString("cat"); err == nil {
+		t.Error("Expected an error for a word shorter than the minimum length")
+	}
+	if err := m.SetMatchString("abcd"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAnchor(l, layout.AnchorStart, 1); err != nil {
+		t.Fatal(err)
+	}
+	if d := m.Difficulty(); math.Abs(d-math.Pow(32, 4)) > 1 {
+		t.Errorf("Expected about 32^4 keys for one anchored word, got %v", d)
+	}
+	one := m.Difficulty()
+	if err := m.SetMatchString("abcd\nefgh"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAnchor(l, layout.AnchorStart, 1); err != nil {
+		t.Fatal(err)
+	}
+	if d := m.Difficulty(); d >= one || d < one/2 {
+		t.Errorf("Expected two words to be about twice as easy as one, got %v and %v", d, one)
+	}
+	// The words do not fit this close to the end.
+	if err := m.SetAnchor(l, layout.AnchorStart, 40); err != nil {
+		t.Fatal(err)
+	}
+	if d := m.Difficulty(); !math.IsInf(d, 1) {
+		t.Errorf("Expected words past the end to never match, got %v", d)
+	}
+	if err := m.SetAnchor(l, layout.AnchorAny, 0); err != nil {
+		t.Fatal(err)
+	}
+	if d := m.Difficulty(); d >= one/30 {
+		t.Errorf("Expected a word anywhere to be easier than anchored, got %v", d)
+	}
+}
+
+// wordsEstimate is the estimate of every word at every offset on its own.
+func wordsEstimate(m *dictionaryMatcher) float64 {
+	l := m.keyLayout
+	e := l.Estimate()
+	for _, word := range m.words {
+		first, last := l.Start, l.End-len(word)
+		switch {
+		case m.start >= 0:
+			first, last = m.start, min(m.start, last)
+		case m.end >= 0:
+			first, last = m.end-len(word), m.end-len(word)
+		}
+		for offset := max(first, l.Start); offset <= last; offset++ {
+			shared, own := 1.0, 1.0
+			for i := range len(word) {
+				p := l.Probability(offset+i, word[i:i+1], true)
+				if l.Shared(offset + i) {
+					shared *= p
+				} else {
+					own *= p
+				}
+			}
+			e.Add(shared, own)
+		}
+	}
+	return e.Keys()
+}
+
+func TestEstimate(t *testing.T) {
+	r := mrand.New(mrand.NewPCG(1, 2)) //nolint:gosec // Reproducible test data.
+	words := make([]string, 100)
+	for i := range words {
+		b := make([]byte, 4+r.IntN(4))
+		for j := range b {
+			b[j] = "abc12"[r.IntN(5)]
+		}
+		words[i] = string(b)
+	}
+	wordlist := strings.Join(words, "\n")
+
+	layouts := map[string]layout.Layout{
+		"ed25519":      ed25519.New(rand.Reader).Layout(),
+		"rsa-2048":     rsa.New(rand.Reader, 2048, rsa.OpenSSH).Layout(),
+		"rsa-exponent": rsa.NewExponentSearch(rand.Reader, 2048, rsa.OpenSSH).Layout(),
+	}
+	for name, l := range layouts {
+		for _, tc := range []struct {
+			anchor   layout.Anchor
+			position int
+		}{
+			{layout.AnchorAny, 0},
+			{layout.AnchorStart, 3},
+			{layout.AnchorEnd, 2},
+		} {
+			m := New()
+			if err := m.SetMatchString(wordlist); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.SetAnchor(l, tc.anchor, tc.position); err != nil {
+				t.Fatal(err)
+			}
+			want, got := wordsEstimate(m), m.Difficulty()
+			if math.Abs(got-want) > want*1e-9 {
+				t.Errorf("%s %s: expected difficulty %v, got %v", name, tc.anchor, want, got)
+			}
+		}
+	}
+}
+
+func BenchmarkDifficulty(b *testing.B) {
+	r := mrand.New(mrand.NewPCG(1, 2)) //nolint:gosec // Reproducible test data.
+	words := make([]string, 300000)
+	for i := range words {
+		w := make([]byte, 4+r.IntN(8))
+		for j := range w {
+			w[j] = symbols[r.IntN(len(symbols))]
+		}
+		words[i] = string(w)
+	}
+	wordlist := strings.Join(words, "\n")
+	l := rsa.New(rand.Reader, 4096, rsa.OpenSSH).Layout()
+	m := New()
+	for b.Loop() {
+		if err := m.SetMatchString(wordlist); err != nil {
+			b.Fatal(err)
+		}
+		if err := m.SetAnchor(l, layout.AnchorAny, 0); err != nil {
+			b.Fatal(err)
+		}
+		_ = m.Difficulty()
+	}
+}
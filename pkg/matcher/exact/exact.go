@@ -0,0 +1,73 @@
+package exact
+
+import (
+	"bytes"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+// exactMatcher matches the public key case-sensitively. Every character of
+// the match string is one of 64 base64 symbols, twice as many as for
+// ignorecase where a letter matches either case.
+type exactMatcher struct {
+	matchString []byte
+	keyLayout   layout.Layout
+	// offset is where the match string has to be, -1 for anywhere.
+	offset int
+	// from and to are the part of the public key that depends on the key,
+	// where the match string is looked for anywhere. to is -1 if the key
+	// layout is not known.
+	from, to int
+}
+
+func New() *exactMatcher {
+	return &exactMatcher{offset: -1, to: -1}
+}
+
+func (m *exactMatcher) SetMatchString(matchString string) error {
+	m.matchString = []byte(matchString)
+	m.offset = -1
+	return layout.CheckBase64(matchString)
+}
+
+func (m *exactMatcher) SetAnchor(l layout.Layout, anchor layout.Anchor, position int) error {
+	offset, err := l.Offset(anchor, position, len(m.matchString))
+	if err != nil {
+		return err
+	}
+	if offset >= 0 {
+		err = l.Check(offset, string(m.matchString), false)
+	} else {
+		err = l.Fits(l.Start, string(m.matchString), false)
+	}
+	if err != nil {
+		return err
+	}
+	m.keyLayout, m.offset, m.from, m.to = l, offset, l.Start, l.End
+	return nil
+}
+
+func (m *exactMatcher) Match(s keygen.SSHKey) bool {
+	pubK := s.SSHPubkey()
+	if m.offset >= 0 {
+		end := m.offset + len(m.matchString)
+		return end <= len(pubK) && bytes.Equal(pubK[m.offset:end], m.matchString)
+	}
+	if m.to >= 0 {
+		// The fixed header is the same for every key, it never matches.
+		if m.to > len(pubK) {
+			return false
+		}
+		pubK = pubK[m.from:m.to]
+	}
+	return bytes.Contains(pubK, m.matchString)
+}
+
+// Difficulty returns the expected number of keys to test for a match. Without
+// a key layout it is estimated for ed25519 keys, longer keys have more
+// positions the match string fits in and need fewer. Characters that are not
+// base64 never match.
+func (m *exactMatcher) Difficulty() float64 {
+	return m.keyLayout.OrEd25519().Difficulty(m.offset, string(m.matchString), false)
+}
@@ -0,0 +1,119 @@
+package exact
+
+import (
+	"crypto/rand"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+type mockSSHKey struct {
+	pubkey []byte
+}
+
+func (m *mockSSHKey) SSHPubkey() []byte {
+	return m.pubkey
+}
+
+func (m *mockSSHKey) SSHPrivkey() []byte {
+	return nil
+}
+
+func (m *mockSSHKey) Generate() {}
+
+func TestExactMatcher(t *testing.T) {
+	m := New()
+	if err := m.SetMatchString("MyTeam"); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		pubkey string
+		match  bool
+	}{
+		{"ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQMyTeam", true},
+		{"ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQmyteam", false},
+		{"ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQMYTEAM", false},
+	}
+
+	for _, tc := range testCases {
+		key := &mockSSHKey{pubkey: []byte(tc.pubkey)}
+		if m.Match(key) != tc.match {
+			t.Errorf("Expected match=%v for pubkey %s", tc.match, tc.pubkey)
+		}
+	}
+}
+
+func TestDifficulty(t *testing.T) {
+	m := New()
+	if err := m.SetMatchString("Ab1"); err != nil {
+		t.Fatal(err)
+	}
+	// Without a key layout it is estimated for ed25519 keys.
+	ed := ed25519.New(rand.Reader).Layout()
+	if d := m.Difficulty(); d != ed.Difficulty(-1, "Ab1", false) || math.Abs(d/(64*64*64/41.0)-1) > 0.1 {
+		t.Errorf("Expected about 64^3/41 keys as for ed25519, got %v", d)
+	}
+	if err := m.SetMatchString("My-Team"); err == nil || !strings.Contains(err.Error(), `try "My+Team"`) {
+		t.Errorf("Expected an error for '-', got %v", err)
+	}
+	if d := m.Difficulty(); !math.IsInf(d, 1) {
+		t.Errorf("Expected a match string with '-' to never match, got %v", d)
+	}
+}
+
+func TestAnchor(t *testing.T) {
+	l := ed25519.New(rand.Reader).Layout()
+	// The key starts at index 37 and ends at index 80.
+	pubkey := &mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMyTeamAbcdefghijklmnopqrstuvwxyz0123456789x\n")}
+	m := New()
+	for _, tc := range []struct {
+		matchString string
+		anchor      layout.Anchor
+		position    int
+		match       bool
+	}{
+		{"MyTeam", layout.AnchorStart, 0, true},
+		{"MYTEAM", layout.AnchorStart, 0, false},
+		{"Team", layout.AnchorStart, 2, true},
+		{"Mx", layout.AnchorStart, 0, false},
+		{"789x", layout.AnchorEnd, 0, true},
+		{"789", layout.AnchorEnd, 1, true},
+		{"789", layout.AnchorEnd, 0, false},
+		{"Abc", layout.AnchorAny, 0, true},
+		// The fixed header is the same for every key and never matches.
+		{"NzaC", layout.AnchorAny, 0, false},
+		{"AAAA", layout.AnchorAny, 0, false},
+	} {
+		if err := m.SetMatchString(tc.matchString); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.SetAnchor(l, tc.anchor, tc.position); err != nil {
+			t.Fatal(err)
+		}
+		if m.Match(pubkey) != tc.match {
+			t.Errorf("Expected match=%v for %q at %s %d", tc.match, tc.matchString, tc.anchor, tc.position)
+		}
+	}
+
+	if err := m.SetMatchString("ZZ"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAnchor(l, layout.AnchorStart, 0); err == nil {
+		t.Error("Expected an error for a match string that can not be at the start")
+	}
+
+	// The first character of an ed25519 key is one of 16 symbols.
+	if err := m.SetMatchString("MyTeam"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAnchor(l, layout.AnchorStart, 0); err != nil {
+		t.Fatal(err)
+	}
+	if d := m.Difficulty(); d != 16*math.Pow(64, 5) {
+		t.Errorf("Expected 16*64^5 keys, got %v", d)
+	}
+}
@@ -0,0 +1,63 @@
+package exacted25519
+
+import (
+	"bytes"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+// exactEd25519Matcher matches ed25519 public keys case-sensitively, only
+// where the key itself is encoded.
+type exactEd25519Matcher struct {
+	matchString []byte
+	keyLayout   layout.Layout
+	// offset is where the match string has to be, -1 for anywhere.
+	offset int
+}
+
+func New() *exactEd25519Matcher {
+	return &exactEd25519Matcher{offset: -1}
+}
+
+func (m *exactEd25519Matcher) SetMatchString(matchString string) error {
+	m.matchString = []byte(matchString)
+	m.offset = -1
+	return layout.CheckBase64(matchString)
+}
+
+func (m *exactEd25519Matcher) SetAnchor(l layout.Layout, anchor layout.Anchor, position int) error {
+	offset, err := l.Offset(anchor, position, len(m.matchString))
+	if err != nil {
+		return err
+	}
+	if offset >= 0 {
+		err = l.Check(offset, string(m.matchString), false)
+	} else {
+		err = l.Fits(layout.Ed25519Start, string(m.matchString), false)
+	}
+	if err != nil {
+		return err
+	}
+	m.keyLayout, m.offset = l, offset
+	return nil
+}
+
+func (m *exactEd25519Matcher) Match(s keygen.SSHKey) bool {
+	pubK := s.SSHPubkey()
+	if m.offset >= 0 {
+		end := m.offset + len(m.matchString)
+		return end <= len(pubK) && bytes.Equal(pubK[m.offset:end], m.matchString)
+	}
+	if len(pubK) < layout.Ed25519Start {
+		return false
+	}
+	return bytes.Contains(pubK[layout.Ed25519Start:], m.matchString)
+}
+
+// Difficulty returns the expected number of keys to test for a match. Without
+// a key layout it is estimated for ed25519 keys. Characters that are not
+// base64 never match.
+func (m *exactEd25519Matcher) Difficulty() float64 {
+	return m.keyLayout.OrEd25519().Difficulty(m.offset, string(m.matchString), false)
+}
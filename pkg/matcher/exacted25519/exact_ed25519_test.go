@@ -0,0 +1,114 @@
+package exacted25519
+
+import (
+	"crypto/rand"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+type mockSSHKey struct {
+	pubkey []byte
+}
+
+func (m *mockSSHKey) SSHPubkey() []byte {
+	return m.pubkey
+}
+
+func (m *mockSSHKey) SSHPrivkey() []byte {
+	return nil
+}
+
+func (m *mockSSHKey) Generate() {}
+
+func TestExactEd25519Matcher(t *testing.T) {
+	m := New()
+	if err := m.SetMatchString("ABC"); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		pubkey string
+		match  bool
+	}{
+		// The matcher starts at index 37
+		{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIABC", true},
+		{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIabc", false},
+		{"ABC", false},
+	}
+
+	for _, tc := range testCases {
+		key := &mockSSHKey{pubkey: []byte(tc.pubkey)}
+		if m.Match(key) != tc.match {
+			t.Errorf("Expected match=%v for pubkey %s", tc.match, tc.pubkey)
+		}
+	}
+
+	// The key type is not part of the key.
+	if err := m.SetMatchString("C3Nza"); err != nil {
+		t.Fatal(err)
+	}
+	if m.Match(&mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIXYZ")}) {
+		t.Error("Expected the encoded key type not to match")
+	}
+}
+
+func TestDifficulty(t *testing.T) {
+	m := New()
+	if err := m.SetMatchString("MyTeam"); err != nil {
+		t.Fatal(err)
+	}
+	// Without a key layout it is estimated for ed25519 keys.
+	ed := ed25519.New(rand.Reader).Layout()
+	if d := m.Difficulty(); d != ed.Difficulty(-1, "MyTeam", false) || math.Abs(d/(math.Pow(64, 6)/38)-1) > 0.1 {
+		t.Errorf("Expected about 64^6/38 keys as for ed25519, got %v", d)
+	}
+	if err := m.SetMatchString("my_team"); err == nil || !strings.Contains(err.Error(), `try "my/team"`) {
+		t.Errorf("Expected an error for '_', got %v", err)
+	}
+	if d := m.Difficulty(); !math.IsInf(d, 1) {
+		t.Errorf("Expected a match string with '_' to never match, got %v", d)
+	}
+}
+
+func TestAnchor(t *testing.T) {
+	l := ed25519.New(rand.Reader).Layout()
+	// The key starts at index 37 and ends at index 80.
+	pubkey := &mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMyTeamAbcdefghijklmnopqrstuvwxyz0123456789x\n")}
+	m := New()
+	for _, tc := range []struct {
+		matchString string
+		anchor      layout.Anchor
+		position    int
+		match       bool
+	}{
+		{"MyTeam", layout.AnchorStart, 0, true},
+		{"MYTEAM", layout.AnchorStart, 0, false},
+		{"Team", layout.AnchorStart, 2, true},
+		{"Mx", layout.AnchorStart, 0, false},
+		{"789x", layout.AnchorEnd, 0, true},
+		{"789", layout.AnchorEnd, 1, true},
+		{"789", layout.AnchorEnd, 0, false},
+		{"Abc", layout.AnchorAny, 0, true},
+	} {
+		if err := m.SetMatchString(tc.matchString); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.SetAnchor(l, tc.anchor, tc.position); err != nil {
+			t.Fatal(err)
+		}
+		if m.Match(pubkey) != tc.match {
+			t.Errorf("Expected match=%v for %q at %s %d", tc.match, tc.matchString, tc.anchor, tc.position)
+		}
+	}
+
+	if err := m.SetMatchString("ZZ"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAnchor(l, layout.AnchorStart, 0); err == nil {
+		t.Error("Expected an error for a match string that can not be at the start")
+	}
+}
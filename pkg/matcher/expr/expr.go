@@ -0,0 +1,304 @@
+package expr
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/exact"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/fingerprint"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/ignorecase"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/regex"
+)
+
+// function is a matcher that expressions can call with a string argument.
+type function struct {
+	new    func() matcher.Matcher
+	anchor layout.Anchor
+	// cost is the time to match a key, relative to an anchored comparison.
+	// The exact numbers do not matter, only their order.
+	cost float64
+}
+
+var functions = map[string]function{
+	"contains":    {func() matcher.Matcher { return exact.New() }, layout.AnchorAny, 10},
+	"icontains":   {func() matcher.Matcher { return ignorecase.New() }, layout.AnchorAny, 20},
+	"prefix":      {func() matcher.Matcher { return exact.New() }, layout.AnchorStart, 1},
+	"iprefix":     {func() matcher.Matcher { return ignorecase.New() }, layout.AnchorStart, 1},
+	"suffix":      {func() matcher.Matcher { return exact.New() }, layout.AnchorEnd, 1},
+	"isuffix":     {func() matcher.Matcher { return ignorecase.New() }, layout.AnchorEnd, 1},
+	"regex":       {func() matcher.Matcher { return regex.New() }, layout.AnchorAny, 30},
+	"iregex":      {func() matcher.Matcher { return regex.NewIgnoreCase() }, layout.AnchorAny, 30},
+	"fingerprint": {func() matcher.Matcher { return fingerprint.New() }, layout.AnchorAny, 40},
+}
+
+// unknownProbability is used for matchers that can not estimate their
+// difficulty.
+const unknownProbability = 0.5
+
+// exprMatcher matches keys against an expression of matchers combined with
+// "&&", "||", "!" and parentheses, like
+//
+//	contains("ops") && suffix("42") && !contains("xxx")
+//
+// The operands of "&&" are evaluated in the order that rejects a key at the
+// lowest expected cost, and the operands of "||" in the order that accepts it
+// at the lowest cost. Both are decided from the cost and the difficulty of
+// every operand.
+type exprMatcher struct {
+	root node
+}
+
+func New() *exprMatcher {
+	return &exprMatcher{}
+}
+
+func (m *exprMatcher) SetMatchString(expression string) error {
+	root, err := parse(expression)
+	if err != nil {
+		m.root = nil
+		return err
+	}
+	m.root = root
+	m.root.order()
+	return nil
+}
+
+// SetAnchor gives the layout of the key type to the matchers of the
+// expression. The anchors are part of the expression, the only anchor allowed
+// here is anywhere.
+func (m *exprMatcher) SetAnchor(l layout.Layout, anchor layout.Anchor, _ int) error {
+	if anchor != layout.AnchorAny {
+		return fmt.Errorf("anchor the matchers in the expression with prefix() and suffix() instead of %s", anchor)
+	}
+	if m.root == nil {
+		return nil
+	}
+	if err := m.root.setLayout(l); err != nil {
+		return err
+	}
+	// The difficulties depend on the layout.
+	m.root.order()
+	return nil
+}
+
+func (m *exprMatcher) Match(s keygen.SSHKey) bool {
+	return m.root != nil && m.root.match(s)
+}
+
+// Difficulty returns the expected number of keys to test for a match,
+// assuming the operands match independently.
+func (m *exprMatcher) Difficulty() float64 {
+	if m.root == nil {
+		return math.Inf(1)
+	}
+	return 1 / m.root.probability()
+}
+
+// String returns the expression in the order it is evaluated.
+func (m *exprMatcher) String() string {
+	if m.root == nil {
+		return ""
+	}
+	return m.root.String()
+}
+
+type node interface {
+	fmt.Stringer
+	match(keygen.SSHKey) bool
+	setLayout(l layout.Layout) error
+	// order sorts the operands, after their probabilities are known.
+	order()
+	// probability is the probability that a random key matches.
+	probability() float64
+	// cost is the expected time to evaluate the node.
+	cost() float64
+}
+
+type call struct {
+	name string
+	arg  string
+	fn   function
+	m    matcher.Matcher
+}
+
+func (c *call) match(s keygen.SSHKey) bool { return c.m.Match(s) }
+
+func (c *call) setLayout(l layout.Layout) error {
+	am, ok := c.m.(matcher.Anchored)
+	if !ok {
+		return nil
+	}
+	if err := am.SetAnchor(l, c.fn.anchor, 0); err != nil {
+		return fmt.Errorf("%s: %w", c, err)
+	}
+	return nil
+}
+
+func (c *call) order() {}
+
+func (c *call) probability() float64 {
+	d, ok := c.m.(matcher.Difficulty)
+	if !ok {
+		return unknownProbability
+	}
+	return min(1/d.Difficulty(), 1)
+}
+
+func (c *call) cost() float64 { return c.fn.cost }
+
+func (c *call) String() string { return c.name + "(" + strconv.Quote(c.arg) + ")" }
+
+type not struct {
+	x node
+}
+
+func (n *not) match(s keygen.SSHKey) bool      { return !n.x.match(s) }
+func (n *not) setLayout(l layout.Layout) error { return n.x.setLayout(l) }
+func (n *not) order()                          { n.x.order() }
+func (n *not) probability() float64            { return 1 - n.x.probability() }
+func (n *not) cost() float64                   { return n.x.cost() }
+func (n *not) String() string                  { return "!" + operand(n.x) }
+
+// and matches if all operands match, it stops at the first that does not.
+type and struct {
+	xs []node
+}
+
+func (n *and) match(s keygen.SSHKey) bool {
+	for _, x := range n.xs {
+		if !x.match(s) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *and) setLayout(l layout.Layout) error {
+	for _, x := range n.xs {
+		if err := x.setLayout(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// order puts the operands with the lowest cost per rejected key first.
+func (n *and) order() {
+	for _, x := range n.xs {
+		x.order()
+	}
+	slices.SortStableFunc(n.xs, func(a, b node) int {
+		return compareRatio(a.cost(), 1-a.probability(), b.cost(), 1-b.probability())
+	})
+}
+
+func (n *and) probability() float64 {
+	p := 1.0
+	for _, x := range n.xs {
+		p *= x.probability()
+	}
+	return p
+}
+
+// cost adds the cost of every operand times the probability that it is
+// evaluated.
+func (n *and) cost() float64 {
+	c, p := 0.0, 1.0
+	for _, x := range n.xs {
+		c += p * x.cost()
+		p *= x.probability()
+	}
+	return c
+}
+
+func (n *and) String() string { return join(n.xs, " && ") }
+
+// or matches if any operand matches, it stops at the first that does.
+type or struct {
+	xs []node
+}
+
+func (n *or) match(s keygen.SSHKey) bool {
+	for _, x := range n.xs {
+		if x.match(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *or) setLayout(l layout.Layout) error {
+	for _, x := range n.xs {
+		if err := x.setLayout(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// order puts the operands with the lowest cost per accepted key first.
+func (n *or) order() {
+	for _, x := range n.xs {
+		x.order()
+	}
+	slices.SortStableFunc(n.xs, func(a, b node) int {
+		return compareRatio(a.cost(), a.probability(), b.cost(), b.probability())
+	})
+}
+
+func (n *or) probability() float64 {
+	q := 1.0
+	for _, x := range n.xs {
+		q *= 1 - x.probability()
+	}
+	return 1 - q
+}
+
+func (n *or) cost() float64 {
+	c, q := 0.0, 1.0
+	for _, x := range n.xs {
+		c += q * x.cost()
+		q *= 1 - x.probability()
+	}
+	return c
+}
+
+func (n *or) String() string { return join(n.xs, " || ") }
+
+// compareRatio compares costA/pA with costB/pB, a zero p is the largest ratio.
+func compareRatio(costA, pA, costB, pB float64) int {
+	switch {
+	case pA <= 0 && pB <= 0:
+		return 0
+	case pA <= 0:
+		return 1
+	case pB <= 0:
+		return -1
+	}
+	return cmp.Compare(costA/pA, costB/pB)
+}
+
+// operand returns x as an operand of an operator, in parentheses if it is an
+// operation itself.
+func operand(x node) string {
+	switch x.(type) {
+	case *and, *or:
+		return "(" + x.String() + ")"
+	}
+	return x.String()
+}
+
+func join(xs []node, op string) string {
+	s := make([]string, len(xs))
+	for i, x := range xs {
+		s[i] = operand(x)
+	}
+	return strings.Join(s, op)
+}
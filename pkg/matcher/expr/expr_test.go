@@ -0,0 +1,185 @@
+package expr
+
+import (
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+type mockSSHKey struct {
+	pubkey []byte
+}
+
+func (m *mockSSHKey) SSHPubkey() []byte {
+	return m.pubkey
+}
+
+func (m *mockSSHKey) SSHPrivkey() []byte {
+	return nil
+}
+
+func (m *mockSSHKey) Generate() {}
+
+func TestExprMatcher(t *testing.T) {
+	l := ed25519.New(rand.Reader).Layout()
+	for _, tc := range []struct {
+		expression string
+		pubkey     string
+		match      bool
+	}{
+		{`contains("ops")`, "xxopsxx", true},
+		{`contains("ops")`, "xxOPSxx", false},
+		{`icontains("ops")`, "xxOPSxx", true},
+		{`contains("ops") && !contains("xxx")`, "opsxx", true},
+		{`contains("ops") && !contains("xxx")`, "opsxxx", false},
+		{`contains("dev") || contains("ops")`, "ops", true},
+		{`contains("dev") || contains("ops")`, "qa", false},
+		{`!(contains("dev") || contains("ops"))`, "qa", true},
+		{`(contains("a") || contains("b")) && contains("c")`, "bc", true},
+		{`(contains("a") || contains("b")) && contains("c")`, "ab", false},
+		{"regex(`o[a-z]s`) && contains(\"\\x6fps\")", "ops", true},
+		{`!!contains("ops")`, "ops", true},
+	} {
+		m := New()
+		if err := m.SetMatchString(tc.expression); err != nil {
+			t.Fatalf("%s: %v", tc.expression, err)
+		}
+		if err := m.SetAnchor(l, layout.AnchorAny, 0); err != nil {
+			t.Fatalf("%s: %v", tc.expression, err)
+		}
+		// The key is padded to its 43 characters after the fixed header.
+		key := &mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI" + tc.pubkey + strings.Repeat("1", 43-len(tc.pubkey)) + "\n")}
+		if m.Match(key) != tc.match {
+			t.Errorf("%s: expected match=%v for %q", tc.expression, tc.match, tc.pubkey)
+		}
+	}
+}
+
+func TestExprAnchors(t *testing.T) {
+	l := ed25519.New(rand.Reader).Layout()
+	// The key starts at index 37 and ends at index 80.
+	pubkey := &mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMopsxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx0w\n")}
+	for _, tc := range []struct {
+		expression string
+		match      bool
+	}{
+		{`prefix("Mops") && suffix("0w")`, true},
+		{`iprefix("mOPS") && isuffix("0W")`, true},
+		{`prefix("Nops")`, false},
+		{`suffix("0")`, false},
+	} {
+		m := New()
+		if err := m.SetMatchString(tc.expression); err != nil {
+			t.Fatalf("%s: %v", tc.expression, err)
+		}
+		if err := m.SetAnchor(l, layout.AnchorAny, 0); err != nil {
+			t.Fatalf("%s: %v", tc.expression, err)
+		}
+		if m.Match(pubkey) != tc.match {
+			t.Errorf("%s: expected match=%v", tc.expression, tc.match)
+		}
+	}
+
+	m := New()
+	if err := m.SetMatchString(`contains("ops")`); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAnchor(l, layout.AnchorStart, 0); err == nil {
+		t.Error("Expected an error for an anchored expression")
+	}
+	// The first character of an ed25519 key is one of A-P.
+	if err := m.SetMatchString(`prefix("z")`); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAnchor(l, layout.AnchorAny, 0); err == nil {
+		t.Error("Expected an error for an impossible prefix")
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	for _, tc := range []struct {
+		expression string
+		err        string
+	}{
+		{``, "empty expression"},
+		{`contains("ops"`, "expected )"},
+		{`contains(ops)`, "expected a quoted string"},
+		{`contains("ops) && x`, "unterminated string"},
+		{`startswith("ops")`, "unknown function startswith"},
+		{`contains("ops") &&`, "unexpected end of expression"},
+		{`contains("ops") contains("dev")`, "unexpected"},
+		{`(contains("ops")`, "expected )"},
+		{`regex("(")`, "missing closing )"},
+		{`contains("my_key")`, `try "my/key"`},
+	} {
+		m := New()
+		if err := m.SetMatchString(tc.expression); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: expected error containing %q, got %v", tc.expression, tc.err, err)
+		}
+		if m.Match(&mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAops\n")}) {
+			t.Errorf("%s: expected no match for an invalid expression", tc.expression)
+		}
+	}
+}
+
+func TestExprOrder(t *testing.T) {
+	l := ed25519.New(rand.Reader).Layout()
+	for _, tc := range []struct {
+		expression string
+		order      string
+	}{
+		// The anchored comparison is the cheapest and the longest string
+		// rejects the most keys.
+		{
+			`contains("ops") && !contains("xxx") && suffix("42") && fingerprint("abc")`,
+			`suffix("42") && contains("ops") && fingerprint("abc") && !contains("xxx")`,
+		},
+		// The most likely operand accepts the most keys.
+		{
+			`contains("abcd") || contains("a")`,
+			`contains("a") || contains("abcd")`,
+		},
+		{
+			`contains("a") && (contains("b") && prefix("C"))`,
+			`prefix("C") && contains("a") && contains("b")`,
+		},
+		{
+			`!(contains("a") || contains("bb")) && contains("ccc")`,
+			`contains("ccc") && !(contains("a") || contains("bb"))`,
+		},
+	} {
+		m := New()
+		if err := m.SetMatchString(tc.expression); err != nil {
+			t.Fatalf("%s: %v", tc.expression, err)
+		}
+		if err := m.SetAnchor(l, layout.AnchorAny, 0); err != nil {
+			t.Fatalf("%s: %v", tc.expression, err)
+		}
+		if got := m.String(); got != tc.order {
+			t.Errorf("%s: expected order %s, got %s", tc.expression, tc.order, got)
+		}
+	}
+}
+
+func TestExprDifficulty(t *testing.T) {
+	m := New()
+	if err := m.SetMatchString(`contains("ab") && contains("cd")`); err != nil {
+		t.Fatal(err)
+	}
+	single := New()
+	if err := single.SetMatchString(`contains("ab")`); err != nil {
+		t.Fatal(err)
+	}
+	if m.Difficulty() <= single.Difficulty() {
+		t.Errorf("Expected both strings to be harder than one, got %f and %f", m.Difficulty(), single.Difficulty())
+	}
+	if err := m.SetMatchString(`contains("ab") || contains("cd")`); err != nil {
+		t.Fatal(err)
+	}
+	if m.Difficulty() >= single.Difficulty() {
+		t.Errorf("Expected either string to be easier than one, got %f and %f", m.Difficulty(), single.Difficulty())
+	}
+}
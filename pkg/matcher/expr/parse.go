@@ -0,0 +1,200 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// parse parses an expression of the grammar
+//
+//	or    = and { "||" and }
+//	and   = unary { "&&" unary }
+//	unary = "!" unary | "(" or ")" | name "(" string ")"
+//
+// where string is a Go string literal in double quotes or backquotes.
+func parse(expression string) (node, error) {
+	p := &parser{s: expression}
+	if strings.TrimSpace(expression) == "" {
+		return nil, errors.New("empty expression")
+	}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	return n, nil
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// consume skips token if it is next.
+func (p *parser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.s[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (node, error) {
+	x, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	xs := []node{x}
+	for p.consume("||") {
+		if x, err = p.and(); err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if len(xs) == 1 {
+		return x, nil
+	}
+	n := &or{}
+	for _, x := range xs {
+		// Parenthesized operands of the same kind are ordered together.
+		if o, ok := x.(*or); ok {
+			n.xs = append(n.xs, o.xs...)
+		} else {
+			n.xs = append(n.xs, x)
+		}
+	}
+	return n, nil
+}
+
+func (p *parser) and() (node, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	xs := []node{x}
+	for p.consume("&&") {
+		if x, err = p.unary(); err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if len(xs) == 1 {
+		return x, nil
+	}
+	n := &and{}
+	for _, x := range xs {
+		if a, ok := x.(*and); ok {
+			n.xs = append(n.xs, a.xs...)
+		} else {
+			n.xs = append(n.xs, x)
+		}
+	}
+	return n, nil
+}
+
+func (p *parser) unary() (node, error) {
+	switch {
+	case p.consume("!"):
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &not{x: x}, nil
+	case p.consume("("):
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("expected )")
+		}
+		return x, nil
+	}
+	return p.call()
+}
+
+func (p *parser) call() (node, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && (p.s[p.pos] >= 'a' && p.s[p.pos] <= 'z' || p.s[p.pos] >= '0' && p.s[p.pos] <= '9') {
+		p.pos++
+	}
+	name := p.s[start:p.pos]
+	if name == "" {
+		if p.pos == len(p.s) {
+			return nil, p.errorf("unexpected end of expression")
+		}
+		return nil, p.errorf("unexpected %q", p.s[p.pos:p.pos+1])
+	}
+	fn, ok := functions[name]
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("unknown function %s, expected one of %s", name, strings.Join(Functions(), ", "))
+	}
+	if !p.consume("(") {
+		return nil, p.errorf("expected ( after %s", name)
+	}
+	arg, err := p.string()
+	if err != nil {
+		return nil, err
+	}
+	if !p.consume(")") {
+		return nil, p.errorf("expected ) after the argument of %s", name)
+	}
+
+	m := fn.new()
+	if err := m.SetMatchString(arg); err != nil {
+		return nil, fmt.Errorf("%s(%q): %w", name, arg, err)
+	}
+	return &call{name: name, arg: arg, fn: fn, m: m}, nil
+}
+
+// string parses a Go string literal.
+func (p *parser) string() (string, error) {
+	p.skipSpace()
+	if p.pos == len(p.s) || p.s[p.pos] != '"' && p.s[p.pos] != '`' {
+		return "", p.errorf("expected a quoted string")
+	}
+	quote := p.s[p.pos]
+	end := p.pos + 1
+	for ; end < len(p.s) && p.s[end] != quote; end++ {
+		if quote == '"' && p.s[end] == '\\' {
+			end++
+		}
+	}
+	if end >= len(p.s) {
+		return "", p.errorf("unterminated string")
+	}
+	s, err := strconv.Unquote(p.s[p.pos : end+1])
+	if err != nil {
+		return "", p.errorf("invalid string %s: %v", p.s[p.pos:end+1], err)
+	}
+	p.pos = end + 1
+	return s, nil
+}
+
+// Functions returns the names of the functions expressions can call.
+func Functions() []string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,135 @@
+package fingerprint
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+// sha256Len is the length of the unpadded base64 SHA256 digest.
+const sha256Len = 43
+
+// sha256LastSymbols are the lower case symbols the last character of the
+// fingerprint can be. It holds the last 4 bits of the digest and 2 zero bits,
+// so only every fourth base64 symbol is possible.
+const sha256LastSymbols = "aeimquycgkosw048"
+
+// fingerprintMatcher matches case-insensitively against the SHA256 fingerprint
+// of the public key, as shown by ssh-keygen -l and ssh-add -l, without the
+// "SHA256:" prefix.
+//
+// The match string can be anchored to the start of the fingerprint with "^"
+// or "SHA256:", and to the end with "$".
+type fingerprintMatcher struct {
+	matchString []byte
+	prefix      bool
+	suffix      bool
+}
+
+func New() *fingerprintMatcher {
+	return &fingerprintMatcher{}
+}
+
+func (m *fingerprintMatcher) SetMatchString(matchString string) error {
+	m.prefix, m.suffix = false, false
+	if s, ok := strings.CutPrefix(matchString, "SHA256:"); ok {
+		matchString, m.prefix = s, true
+	}
+	if s, ok := strings.CutPrefix(matchString, "^"); ok {
+		matchString, m.prefix = s, true
+	}
+	if s, ok := strings.CutSuffix(matchString, "$"); ok {
+		matchString, m.suffix = s, true
+	}
+	m.matchString = []byte(strings.ToLower(matchString))
+	// The fingerprint is unpadded base64, like the public key.
+	if err := layout.CheckBase64(matchString); err != nil {
+		return err
+	}
+	if m.prefix && m.suffix && len(m.matchString) != sha256Len {
+		return fmt.Errorf("%q can never match, the fingerprint is %d characters", matchString, sha256Len)
+	}
+	if last := m.matchString[len(m.matchString)-1]; m.suffix && strings.IndexByte(sha256LastSymbols, last) < 0 {
+		return fmt.Errorf("%q can never match, the last character of the fingerprint is one of %q",
+			matchString, sha256LastSymbols)
+	}
+	return nil
+}
+
+func (m *fingerprintMatcher) Match(s keygen.SSHKey) bool {
+	var fp [sha256Len]byte
+	if !fingerprintSHA256(s.SSHPubkey(), &fp) {
+		return false
+	}
+	for i, c := range fp {
+		if c >= 'A' && c <= 'Z' {
+			fp[i] = c + 'a' - 'A'
+		}
+	}
+	return match(fp[:], m.matchString, m.prefix, m.suffix)
+}
+
+// Difficulty returns the expected number of keys to test for a match. Letters
+// match either case, 2 of the 64 base64 symbols, other symbols only 1. The last
+// character of the fingerprint is one of 16 symbols, of which a valid match
+// string character matches 1.
+func (m *fingerprintMatcher) Difficulty() float64 {
+	keys := 1.0
+	for i, c := range m.matchString {
+		switch {
+		case m.suffix && i == len(m.matchString)-1:
+			keys *= 16
+		case c >= 'a' && c <= 'z':
+			keys *= 32
+		default:
+			keys *= 64
+		}
+	}
+	return difficulty(keys, sha256Len, len(m.matchString), m.prefix, m.suffix)
+}
+
+func match(fp, matchString []byte, prefix, suffix bool) bool {
+	switch {
+	case prefix && suffix:
+		return bytes.Equal(fp, matchString)
+	case prefix:
+		return bytes.HasPrefix(fp, matchString)
+	case suffix:
+		return bytes.HasSuffix(fp, matchString)
+	default:
+		return bytes.Contains(fp, matchString)
+	}
+}
+
+// difficulty divides the expected number of keys to match at one position by
+// the number of positions the match string fits in.
+func difficulty(keysPerPosition float64, fpLen, matchLen int, prefix, suffix bool) float64 {
+	if matchLen > fpLen {
+		return math.Inf(1)
+	}
+	positions := 1
+	if !prefix && !suffix {
+		positions = fpLen - matchLen + 1
+	}
+	return keysPerPosition / float64(positions)
+}
+
+// fingerprintSHA256 writes the base64 SHA256 fingerprint of an
+// authorized_keys line to fp, like ssh.FingerprintSHA256 without the "SHA256:"
+// prefix. It reports false if the line can not be decoded.
+func fingerprintSHA256(pubK []byte, fp *[sha256Len]byte) bool {
+	var buf [layout.MaxBlobLen]byte
+	blob, ok := layout.DecodeBlob(pubK, buf[:])
+	if !ok {
+		return false
+	}
+	sum := sha256.Sum256(blob)
+	base64.RawStdEncoding.Encode(fp[:], sum[:])
+	return true
+}
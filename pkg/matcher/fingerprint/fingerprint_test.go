@@ -0,0 +1,147 @@
+package fingerprint
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"math"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ecdsa"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/rsa"
+)
+
+type mockSSHKey struct {
+	pubkey []byte
+}
+
+func (m *mockSSHKey) SSHPubkey() []byte  { return m.pubkey }
+func (m *mockSSHKey) SSHPrivkey() []byte { return nil }
+func (m *mockSSHKey) Generate()          {}
+
+func TestFingerprintSHA256(t *testing.T) {
+	keys := map[string]keygen.SSHKey{
+		"ed25519":    ed25519.New(rand.Reader),
+		"ecdsa-p521": ecdsa.New(rand.Reader, elliptic.P521()),
+		"rsa-4096":   rsa.New(rand.Reader, 4096, rsa.OpenSSH),
+	}
+	for name, k := range keys {
+		t.Run(name, func(t *testing.T) {
+			k.Generate()
+			pub, _, _, _, err := ssh.ParseAuthorizedKey(k.SSHPubkey())
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := ssh.FingerprintSHA256(pub)
+
+			var fp [sha256Len]byte
+			if !fingerprintSHA256(k.SSHPubkey(), &fp) {
+				t.Fatal("Could not compute fingerprint")
+			}
+			if "SHA256:"+string(fp[:]) != want {
+				t.Errorf("Got SHA256:%s, want %s", fp, want)
+			}
+
+			m := New()
+			for _, matchString := range []string{
+				want,
+				strings.ToUpper(want[7:12]),
+				"^" + want[7:12],
+				want[len(want)-5:] + "$",
+				"^" + want[7:] + "$",
+			} {
+				if err := m.SetMatchString(matchString); err != nil {
+					t.Fatal(err)
+				}
+				if !m.Match(k) {
+					t.Errorf("Expected %q to match %s", matchString, want)
+				}
+			}
+			// The anchors swapped around, these only match by chance
+			// with a probability of about 2^-40. A suffix the last
+			// character can not hold is rejected instead.
+			for _, matchString := range []string{
+				"^" + want[len(want)-10:],
+				want[7:17] + "$",
+			} {
+				if err := m.SetMatchString(matchString); err != nil {
+					continue
+				}
+				if m.Match(k) {
+					t.Errorf("Expected %q not to match %s", matchString, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFingerprintMatcherInvalidKey(t *testing.T) {
+	m := New()
+	if err := m.SetMatchString("a"); err != nil {
+		t.Fatal(err)
+	}
+	for _, pub := range []string{"", "ssh-ed25519", "ssh-ed25519 !!!!\n"} {
+		if m.Match(&mockSSHKey{pubkey: []byte(pub)}) {
+			t.Errorf("Expected %q not to match", pub)
+		}
+	}
+}
+
+func TestFingerprintMatcherLastCharacter(t *testing.T) {
+	m := New()
+	for _, matchString := range []string{
+		"b$",
+		"aB$",
+		"+$",
+		"^" + strings.Repeat("a", sha256Len-1) + "b$",
+		"^" + strings.Repeat("a", sha256Len-1) + "$",
+	} {
+		if err := m.SetMatchString(matchString); err == nil {
+			t.Errorf("Expected %q to fail", matchString)
+		}
+	}
+
+	for matchString, want := range map[string]float64{
+		"a$":  16,
+		"W$":  16,
+		"4$":  16,
+		"ba$": 32 * 16,
+		"b":   32.0 / sha256Len,
+		"^b":  32,
+		"^" + strings.Repeat("1", sha256Len-1) + "0$": math.Pow(64, sha256Len-1) * 16,
+	} {
+		if err := m.SetMatchString(matchString); err != nil {
+			t.Fatal(err)
+		}
+		if got := m.Difficulty(); got != want {
+			t.Errorf("%q: got difficulty %v, want %v", matchString, got, want)
+		}
+	}
+}
+
+// TestFingerprintMatcherLastSymbols checks sha256LastSymbols against the last
+// character of real fingerprints.
+func TestFingerprintMatcherLastSymbols(t *testing.T) {
+	seen := map[byte]bool{}
+	for range 1000 {
+		k := ed25519.New(rand.Reader)
+		k.Generate()
+		var fp [sha256Len]byte
+		if !fingerprintSHA256(k.SSHPubkey(), &fp) {
+			t.Fatal("Could not compute fingerprint")
+		}
+		seen[strings.ToLower(string(fp[sha256Len-1:]))[0]] = true
+	}
+	for c := range seen {
+		if strings.IndexByte(sha256LastSymbols, c) < 0 {
+			t.Errorf("Fingerprint ends in %q, not one of %q", c, sha256LastSymbols)
+		}
+	}
+	if len(seen) != len(sha256LastSymbols) {
+		t.Errorf("Got %d last symbols in 1000 fingerprints, want %d", len(seen), len(sha256LastSymbols))
+	}
+}
@@ -0,0 +1,106 @@
+package fingerprint
+
+import (
+	"bytes"
+	"crypto/md5" //nolint:gosec // MD5 fingerprints are matched, not used for security.
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+// md5Len is the length of the hex MD5 digest without colons.
+const md5Len = 2 * md5.Size
+
+// md5Matcher matches against the legacy MD5 fingerprint, as shown by
+// ssh-keygen -l -E md5. The match string is hex, with or without the colons
+// between bytes. With colons it only matches where they line up with the
+// colons of the fingerprint.
+//
+// The match string can be anchored to the start of the fingerprint with "^"
+// or "MD5:", and to the end with "$".
+type md5Matcher struct {
+	matchString []byte
+	prefix      bool
+	suffix      bool
+	// parity is the offset modulo 2 the match string has to start at, -1
+	// for any offset.
+	parity int
+}
+
+func NewMD5() *md5Matcher {
+	return &md5Matcher{parity: -1}
+}
+
+func (m *md5Matcher) SetMatchString(matchString string) error {
+	m.prefix, m.suffix = false, false
+	if s, ok := strings.CutPrefix(matchString, "MD5:"); ok {
+		matchString, m.prefix = s, true
+	}
+	if s, ok := strings.CutPrefix(matchString, "^"); ok {
+		matchString, m.prefix = s, true
+	}
+	if s, ok := strings.CutSuffix(matchString, "$"); ok {
+		matchString, m.suffix = s, true
+	}
+	m.parity = -1
+	if groups := strings.Split(matchString, ":"); len(groups) > 1 {
+		for i, g := range groups {
+			if len(g) > 2 || len(g) < 2 && i > 0 && i < len(groups)-1 {
+				return fmt.Errorf("%q can never match, the colons are not 2 hex digits apart", matchString)
+			}
+		}
+		// The first colon is at a byte boundary.
+		m.parity = len(groups[0]) % 2
+	}
+	hexString := strings.ReplaceAll(matchString, ":", "")
+	m.matchString = []byte(strings.ToLower(hexString))
+	if len(m.matchString) == 0 {
+		return errors.New("empty match string")
+	}
+	for _, c := range m.matchString {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return fmt.Errorf("%q can never match, %q is not a hex digit", matchString, c)
+		}
+	}
+	if m.parity >= 0 && (m.prefix && m.parity != 0 || m.suffix && (md5Len-len(m.matchString))%2 != m.parity ||
+		m.parity+len(m.matchString) > md5Len) {
+		return fmt.Errorf("%q can never match, its colons are not between bytes", matchString)
+	}
+	return nil
+}
+
+func (m *md5Matcher) Match(s keygen.SSHKey) bool {
+	var buf [layout.MaxBlobLen]byte
+	blob, ok := layout.DecodeBlob(s.SSHPubkey(), buf[:])
+	if !ok {
+		return false
+	}
+	sum := md5.Sum(blob) //nolint:gosec // MD5 fingerprints are matched, not used for security.
+	var fp [md5Len]byte
+	hex.Encode(fp[:], sum[:])
+	if m.parity < 0 || m.prefix || m.suffix {
+		return match(fp[:], m.matchString, m.prefix, m.suffix)
+	}
+	for i := m.parity; i+len(m.matchString) <= md5Len; i += 2 {
+		if bytes.Equal(fp[i:i+len(m.matchString)], m.matchString) {
+			return true
+		}
+	}
+	return false
+}
+
+// Difficulty returns the expected number of keys to test for a match. Every
+// hex digit matches with a probability of 1/16. With colons the match string
+// only fits in every other position.
+func (m *md5Matcher) Difficulty() float64 {
+	keys := math.Pow(16, float64(len(m.matchString)))
+	if m.parity < 0 || m.prefix || m.suffix {
+		return difficulty(keys, md5Len, len(m.matchString), m.prefix, m.suffix)
+	}
+	return keys / float64((md5Len-len(m.matchString)-m.parity)/2+1)
+}
@@ -0,0 +1,115 @@
+package fingerprint
+
+import (
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/rsa"
+)
+
+func TestMD5Matcher(t *testing.T) {
+	keys := map[string]keygen.SSHKey{
+		"ed25519":  ed25519.New(rand.Reader),
+		"rsa-2048": rsa.New(rand.Reader, 2048, rsa.OpenSSH),
+	}
+	for name, k := range keys {
+		t.Run(name, func(t *testing.T) {
+			k.Generate()
+			pub, _, _, _, err := ssh.ParseAuthorizedKey(k.SSHPubkey())
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := ssh.FingerprintLegacyMD5(pub)
+
+			m := NewMD5()
+			if err := m.SetMatchString("MD5:" + want); err != nil {
+				t.Fatal(err)
+			}
+			if !m.Match(k) {
+				t.Errorf("Expected the full fingerprint to match %s", want)
+			}
+			if err := m.SetMatchString(strings.ReplaceAll(want, ":", "")); err != nil {
+				t.Fatal(err)
+			}
+			if !m.Match(k) {
+				t.Errorf("Expected the fingerprint without colons to match %s", want)
+			}
+		})
+	}
+}
+
+func TestMD5MatcherAnchors(t *testing.T) {
+	// MD5:0b:d3:c5:75:2e:5b:bf:14:57:64:b9:c7:5d:87:03:b1
+	k := &mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIEX3NxBGpoOhZz0zcS9d1MxbwBhDCFXVKsoWBIMt3Auz\n")}
+
+	m := NewMD5()
+	for matchString, match := range map[string]bool{
+		"0bd3":                               true,
+		"0B:D3:C5":                           true,
+		"2e5bbf":                             true,
+		"^0b:d3":                             true,
+		"MD5:0b:d3":                          true,
+		"^d3c5":                              false,
+		"MD5:d3c5":                           false,
+		"03b1$":                              true,
+		"03:b1$":                             true,
+		"87:03$":                             false,
+		"^0bd3c5752e5bbf145764b9c75d8703b1$": true,
+		// Colons only match between bytes.
+		"d3:c5":  true,
+		"b:d3":   true,
+		"5:2e":   true,
+		"3c57":   true,
+		"3c:57":  false,
+		"d:3c":   false,
+		"3:b1$":  true,
+		"MD5:0b": true,
+	} {
+		if err := m.SetMatchString(matchString); err != nil {
+			t.Fatal(err)
+		}
+		if m.Match(k) != match {
+			t.Errorf("Expected match=%v for %q", match, matchString)
+		}
+	}
+	for _, matchString := range []string{"zz", "MD5:", "0b-d3", "abc:d", "a:bcd:e", "^b:d3", "87:0$"} {
+		if err := m.SetMatchString(matchString); err == nil {
+			t.Errorf("Expected an error for %q", matchString)
+		}
+	}
+}
+
+func TestDifficulty(t *testing.T) {
+	m := NewMD5()
+	if err := m.SetMatchString("^ab:cd"); err != nil {
+		t.Fatal(err)
+	}
+	if d := m.Difficulty(); d != 65536 {
+		t.Errorf("Expected 16^4 keys for an anchored 4 digit match, got %v", d)
+	}
+	if err := m.SetMatchString("abcd"); err != nil {
+		t.Fatal(err)
+	}
+	if d := m.Difficulty(); d != 65536.0/29 {
+		t.Errorf("Expected 16^4/29 keys for a 4 digit match, got %v", d)
+	}
+	if err := m.SetMatchString("ab:cd"); err != nil {
+		t.Fatal(err)
+	}
+	if d := m.Difficulty(); d != 65536.0/15 {
+		t.Errorf("Expected 16^4/15 keys for 2 bytes, got %v", d)
+	}
+
+	f := New()
+	if err := f.SetMatchString("^a1"); err != nil {
+		t.Fatal(err)
+	}
+	if d := f.Difficulty(); d != 32*64 {
+		t.Errorf("Expected 32*64 keys for an anchored match, got %v", d)
+	}
+}
@@ -0,0 +1,204 @@
+package fingerprint
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+// Size of the randomart field, as in OpenSSH.
+const (
+	RandomartWidth  = 17
+	RandomartHeight = 9
+)
+
+// randomartSymbols are the symbols for cells visited 0 to 14 times, followed
+// by the start and end markers. Cells visited more often keep the symbol
+// for 14 visits.
+const randomartSymbols = " .o+=*BOX@%&#/^SE"
+
+// Randomart is the field drawn by the drunken bishop, one symbol per cell.
+type Randomart [RandomartHeight][RandomartWidth]byte
+
+// DrunkenBishop draws the randomart of a fingerprint digest, like ssh-keygen
+// -lv does. The bishop starts in the centre of the field and makes four moves
+// per digest byte, two bits each, least significant first.
+func DrunkenBishop(digest []byte) Randomart {
+	const maxVisits = len(randomartSymbols) - 3
+	var visits [RandomartHeight][RandomartWidth]int
+	x, y := RandomartWidth/2, RandomartHeight/2
+	for _, b := range digest {
+		for range 4 {
+			if b&1 != 0 {
+				x++
+			} else {
+				x--
+			}
+			if b&2 != 0 {
+				y++
+			} else {
+				y--
+			}
+			x = min(max(x, 0), RandomartWidth-1)
+			y = min(max(y, 0), RandomartHeight-1)
+			if visits[y][x] < maxVisits {
+				visits[y][x]++
+			}
+			b >>= 2
+		}
+	}
+
+	var r Randomart
+	for row := range visits {
+		for col, v := range visits[row] {
+			r[row][col] = randomartSymbols[v]
+		}
+	}
+	r[RandomartHeight/2][RandomartWidth/2] = 'S'
+	r[y][x] = 'E'
+	return r
+}
+
+// Format returns the randomart in a frame with the title at the top and the
+// hash name at the bottom, like "ED25519 256" and "SHA256".
+func (r *Randomart) Format(title, hash string) string {
+	var b strings.Builder
+	frame(&b, "["+title+"]")
+	for _, row := range r {
+		b.WriteByte('|')
+		b.Write(row[:])
+		b.WriteString("|\n")
+	}
+	frame(&b, "["+hash+"]")
+	return b.String()
+}
+
+// frame writes a border line with the label centred, rounded to the left.
+func frame(b *strings.Builder, label string) {
+	if len(label) > RandomartWidth {
+		label = label[:RandomartWidth]
+	}
+	left := (RandomartWidth - len(label)) / 2
+	b.WriteByte('+')
+	b.WriteString(strings.Repeat("-", left))
+	b.WriteString(label)
+	b.WriteString(strings.Repeat("-", RandomartWidth-left-len(label)))
+	b.WriteString("+\n")
+}
+
+// KeyRandomart returns the randomart of the SHA256 fingerprint of a public
+// key, as printed by ssh-keygen -lv.
+func KeyRandomart(pub ssh.PublicKey) string {
+	digest := sha256.Sum256(pub.Marshal())
+	r := DrunkenBishop(digest[:])
+	return r.Format(keyTitle(pub), "SHA256")
+}
+
+// keyTitle returns the key type and size, like "ED25519 256".
+func keyTitle(pub ssh.PublicKey) string {
+	switch pub.Type() {
+	case ssh.KeyAlgoED25519:
+		return "ED25519 256"
+	case ssh.KeyAlgoECDSA256:
+		return "ECDSA 256"
+	case ssh.KeyAlgoECDSA384:
+		return "ECDSA 384"
+	case ssh.KeyAlgoECDSA521:
+		return "ECDSA 521"
+	case ssh.KeyAlgoRSA:
+		if k, ok := pub.(ssh.CryptoPublicKey); ok {
+			if rsaKey, ok := k.CryptoPublicKey().(*rsa.PublicKey); ok {
+				return fmt.Sprintf("RSA %d", rsaKey.N.BitLen())
+			}
+		}
+		return "RSA"
+	default:
+		return pub.Type()
+	}
+}
+
+// Cells of a randomart mask that are not a randomart symbol.
+const (
+	maskAny      = '?'
+	maskNonEmpty = '!'
+)
+
+// randomartMatcher matches keys whose SHA256 randomart fits a mask. The mask
+// is drawn like the randomart printed by ssh-keygen -lv, optionally with the
+// frame. Each cell is one of:
+//
+//	?  any symbol
+//	!  any symbol but empty
+//	   (space) must be empty
+//
+// or any other randomart symbol, including S and E, that must be in the cell.
+// Missing rows and cells at the end of a row match any symbol.
+type randomartMatcher struct {
+	mask Randomart
+}
+
+func NewRandomart() *randomartMatcher {
+	return &randomartMatcher{}
+}
+
+func (m *randomartMatcher) SetMatchString(mask string) error {
+	for row := range m.mask {
+		for col := range m.mask[row] {
+			m.mask[row][col] = maskAny
+		}
+	}
+	row := 0
+	for line := range strings.Lines(mask) {
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "+") {
+			continue
+		}
+		if row == RandomartHeight {
+			break
+		}
+		if s, ok := strings.CutPrefix(line, "|"); ok {
+			line, _ = strings.CutSuffix(s, "|")
+		}
+		for _, c := range []byte(line) {
+			if c != maskAny && c != maskNonEmpty && strings.IndexByte(randomartSymbols, c) < 0 {
+				return fmt.Errorf("row %d: %q is not a randomart symbol, use one of %q, ? or !", row+1, c, randomartSymbols)
+			}
+		}
+		copy(m.mask[row][:], line)
+		row++
+	}
+	return nil
+}
+
+func (m *randomartMatcher) Match(s keygen.SSHKey) bool {
+	var buf [layout.MaxBlobLen]byte
+	blob, ok := layout.DecodeBlob(s.SSHPubkey(), buf[:])
+	if !ok {
+		return false
+	}
+	digest := sha256.Sum256(blob)
+	r := DrunkenBishop(digest[:])
+	for row := range m.mask {
+		for col, want := range m.mask[row] {
+			got := r[row][col]
+			switch want {
+			case maskAny:
+			case maskNonEmpty:
+				if got == ' ' {
+					return false
+				}
+			default:
+				if got != want {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package fingerprint
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Output of ssh-keygen -lv for the key of randomartKey.
+const randomartWant = `+--[ED25519 256]--+
+|                 |
+|       . .       |
+|        =        |
+|       +E*       |
+|     . oS.. .    |
+|    = o*oo.=. .  |
+|   + *o+= o=*. = |
+|    + +=. oo++o.o|
+|     .o... ..=*. |
++----[SHA256]-----+
+`
+
+var randomartKey = &mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIEX3NxBGpoOhZz0zcS9d1MxbwBhDCFXVKsoWBIMt3Auz\n")}
+
+func TestKeyRandomart(t *testing.T) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(randomartKey.SSHPubkey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := KeyRandomart(pub); got != randomartWant {
+		t.Errorf("Unexpected randomart:\n%s\nwant:\n%s", got, randomartWant)
+	}
+}
+
+func TestRandomartMatcher(t *testing.T) {
+	m := NewRandomart()
+	for mask, match := range map[string]bool{
+		randomartWant: true,
+		"":            true,
+		// Without the frame.
+		"\n       . .\n        =\n       +E*\n": true,
+		"\n       ! !\n        !\n       !!!\n": true,
+		"\n       ? ?\n        ?\n       ???\n": true,
+		"\n       .\n        =\n       +S*\n":   false,
+		"\n       . .\n         !\n":            false,
+		"|!":                                    false,
+		"|    ?":                                true,
+		"|                 |\n|       o":        false,
+	} {
+		if err := m.SetMatchString(mask); err != nil {
+			t.Fatal(err)
+		}
+		if m.Match(randomartKey) != match {
+			t.Errorf("Expected match=%v for mask\n%s", match, mask)
+		}
+	}
+	if err := m.SetMatchString("|   x"); err == nil {
+		t.Error("Expected an error for a cell that is not a randomart symbol")
+	}
+}
+
+func TestDrunkenBishop(t *testing.T) {
+	// Four moves up and left from the centre, then twelve down and right
+	// back over the same cells and through the centre to the bottom right corner.
+	r := DrunkenBishop([]byte{0x00, 0xff, 0xff, 0xff})
+	want := "+-------[]--------+\n" +
+		"|    .            |\n" +
+		"|     o           |\n" +
+		"|      o          |\n" +
+		"|       o         |\n" +
+		"|        S        |\n" +
+		"|         .       |\n" +
+		"|          .      |\n" +
+		"|           .     |\n" +
+		"|            ....E|\n" +
+		"+-----[TEST]------+\n"
+	if got := r.Format("", "TEST"); got != want {
+		t.Errorf("Unexpected randomart:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -4,25 +4,69 @@ import (
 	"strings"
 
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
 )
 
 type ignorecaseMatcher struct {
 	matchString string
+	keyLayout   layout.Layout
+	// offset is where the match string has to be, -1 for anywhere.
+	offset int
+	// from and to are the part of the public key that depends on the key,
+	// where the match string is looked for anywhere. to is -1 if the key
+	// layout is not known.
+	from, to int
 }
 
 func New() *ignorecaseMatcher {
-	return &ignorecaseMatcher{}
+	return &ignorecaseMatcher{offset: -1, to: -1}
 }
 
-func (m *ignorecaseMatcher) SetMatchString(matchString string) {
+func (m *ignorecaseMatcher) SetMatchString(matchString string) error {
 	m.matchString = strings.ToLower(matchString)
+	m.offset = -1
+	return layout.CheckBase64(matchString)
+}
+
+func (m *ignorecaseMatcher) SetAnchor(l layout.Layout, anchor layout.Anchor, position int) error {
+	offset, err := l.Offset(anchor, position, len(m.matchString))
+	if err != nil {
+		return err
+	}
+	if offset >= 0 {
+		err = l.Check(offset, m.matchString, true)
+	} else {
+		err = l.Fits(l.Start, m.matchString, true)
+	}
+	if err != nil {
+		return err
+	}
+	m.keyLayout, m.offset, m.from, m.to = l, offset, l.Start, l.End
+	return nil
 }
 
 func (m *ignorecaseMatcher) Match(s keygen.SSHKey) bool {
 	pubK := s.SSHPubkey()
+	if m.offset >= 0 {
+		end := m.offset + len(m.matchString)
+		return end <= len(pubK) && containsCaseInsensitive(pubK[m.offset:end], m.matchString)
+	}
+	if m.to >= 0 {
+		// The fixed header is the same for every key, it never matches.
+		if m.to > len(pubK) {
+			return false
+		}
+		pubK = pubK[m.from:m.to]
+	}
 	return containsCaseInsensitive(pubK, m.matchString)
 }
 
+// Difficulty returns the expected number of keys to test for a match. Without
+// a key layout it is estimated for ed25519 keys.
+func (m *ignorecaseMatcher) Difficulty() float64 {
+	return m.keyLayout.OrEd25519().Difficulty(m.offset, m.matchString, true)
+}
+
 func containsCaseInsensitive(b []byte, substr string) bool {
 	if len(substr) == 0 {
 		return true
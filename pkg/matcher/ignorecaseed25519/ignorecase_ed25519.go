@@ -4,27 +4,60 @@ import (
 	"strings"
 
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
 )
 
 type ignorecaseEd25519Matcher struct {
 	matchString []byte
+	keyLayout   layout.Layout
+	// offset is where the match string has to be, -1 for anywhere.
+	offset int
 }
 
 func New() *ignorecaseEd25519Matcher {
-	return &ignorecaseEd25519Matcher{}
+	return &ignorecaseEd25519Matcher{offset: -1}
 }
 
-func (m *ignorecaseEd25519Matcher) SetMatchString(matchString string) {
+func (m *ignorecaseEd25519Matcher) SetMatchString(matchString string) error {
 	m.matchString = []byte(strings.ToLower(matchString))
+	m.offset = -1
+	return layout.CheckBase64(matchString)
+}
+
+func (m *ignorecaseEd25519Matcher) SetAnchor(l layout.Layout, anchor layout.Anchor, position int) error {
+	offset, err := l.Offset(anchor, position, len(m.matchString))
+	if err != nil {
+		return err
+	}
+	if offset >= 0 {
+		err = l.Check(offset, string(m.matchString), true)
+	} else {
+		err = l.Fits(layout.Ed25519Start, string(m.matchString), true)
+	}
+	if err != nil {
+		return err
+	}
+	m.keyLayout, m.offset = l, offset
+	return nil
 }
 
 func (m *ignorecaseEd25519Matcher) Match(s keygen.SSHKey) bool {
 	pubK := s.SSHPubkey()
-	if len(pubK) < 37 {
+	if m.offset >= 0 {
+		end := m.offset + len(m.matchString)
+		return end <= len(pubK) && containsCaseInsensitive(pubK[m.offset:end], m.matchString)
+	}
+	if len(pubK) < layout.Ed25519Start {
 		return false
 	}
 	// The public key is 81 bytes long. The base64 part starts at index 37.
-	return containsCaseInsensitive(pubK[37:], m.matchString)
+	return containsCaseInsensitive(pubK[layout.Ed25519Start:], m.matchString)
+}
+
+// Difficulty returns the expected number of keys to test for a match. Without
+// a key layout it is estimated for ed25519 keys.
+func (m *ignorecaseEd25519Matcher) Difficulty() float64 {
+	return m.keyLayout.OrEd25519().Difficulty(m.offset, string(m.matchString), true)
 }
 
 func containsCaseInsensitive(b, substr []byte) bool {
@@ -1,7 +1,11 @@
 package ignorecaseed25519
 
 import (
+	"crypto/rand"
 	"testing"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
 )
 
 type mockSSHKey struct {
@@ -20,7 +24,9 @@ func (m *mockSSHKey) Generate() {}
 
 func TestIgnoreCaseEd25519Matcher(t *testing.T) {
 	m := New()
-	m.SetMatchString("abc")
+	if err := m.SetMatchString("abc"); err != nil {
+		t.Fatal(err)
+	}
 
 	testCases := []struct {
 		pubkey string
@@ -42,9 +48,50 @@ func TestIgnoreCaseEd25519Matcher(t *testing.T) {
 
 func BenchmarkMatcherMatch(b *testing.B) {
 	m := New()
-	m.SetMatchString("abc")
+	if err := m.SetMatchString("abc"); err != nil {
+		b.Fatal(err)
+	}
 	key := &mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIABCDEFGH")}
 	for i := 0; i < b.N; i++ {
 		_ = m.Match(key)
 	}
 }
+
+func TestAnchor(t *testing.T) {
+	l := ed25519.New(rand.Reader).Layout()
+	// The key starts at index 37 and ends at index 80.
+	pubkey := &mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMyTeamAbcdefghijklmnopqrstuvwxyz0123456789x\n")}
+	m := New()
+	for _, tc := range []struct {
+		matchString string
+		anchor      layout.Anchor
+		position    int
+		match       bool
+	}{
+		{"myteam", layout.AnchorStart, 0, true},
+		{"MYTEAM", layout.AnchorStart, 0, true},
+		{"team", layout.AnchorStart, 2, true},
+		{"mx", layout.AnchorStart, 0, false},
+		{"789X", layout.AnchorEnd, 0, true},
+		{"789", layout.AnchorEnd, 1, true},
+		{"789", layout.AnchorEnd, 0, false},
+		{"abc", layout.AnchorAny, 0, true},
+	} {
+		if err := m.SetMatchString(tc.matchString); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.SetAnchor(l, tc.anchor, tc.position); err != nil {
+			t.Fatal(err)
+		}
+		if m.Match(pubkey) != tc.match {
+			t.Errorf("Expected match=%v for %q at %s %d", tc.match, tc.matchString, tc.anchor, tc.position)
+		}
+	}
+
+	if err := m.SetMatchString("zz"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAnchor(l, layout.AnchorStart, 0); err == nil {
+		t.Error("Expected an error for a match string that can not be at the start")
+	}
+}
@@ -2,13 +2,51 @@ package matcher
 
 import (
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
 )
 
 type Matcher interface {
-	SetMatchString(string)
+	// SetMatchString returns an error if the match string is invalid or no
+	// key can ever match it.
+	SetMatchString(string) error
 	Match(keygen.SSHKey) bool
 }
 
+// Difficulty is implemented by matchers that can estimate how many keys have
+// to be tested on average to find a match.
+type Difficulty interface {
+	Difficulty() float64
+}
+
+// Locator is implemented by matchers that can tell which of their patterns
+// matched a key, and at which offset of the public key.
+type Locator interface {
+	Locate(keygen.SSHKey) (pattern string, offset int, ok bool)
+}
+
+// Remover is implemented by matchers with several patterns that can stop
+// matching a pattern while keys are matched, once it has a key.
+type Remover interface {
+	Locator
+	// Remove reports false if the pattern is not matched.
+	Remove(pattern string) bool
+	// Patterns returns the patterns that are still matched.
+	Patterns() []string
+}
+
+// MinLength is implemented by matchers that accept any word of a wordlist that
+// is long enough. SetMinLength is called before SetMatchString.
+type MinLength interface {
+	SetMinLength(n int)
+}
+
+// Anchored is implemented by matchers that can match at a single offset of the
+// public key instead of scanning all of it. SetAnchor is called after
+// SetMatchString, with the layout of the key type that is searched.
+type Anchored interface {
+	SetAnchor(l layout.Layout, anchor layout.Anchor, position int) error
+}
+
 type namedMatcher struct {
 	name    string
 	matcher Matcher
@@ -1,13 +1,25 @@
 package matcher
 
 import (
+	crand "crypto/rand"
 	"math/rand/v2"
 	"slices"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/bitmask"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/dictionary"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/exact"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/exacted25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/fingerprint"
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/ignorecase"
 	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/ignorecaseed25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/multi"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/matcher/regex"
 )
 
 type testKey []byte
@@ -26,8 +38,8 @@ func (k testKey) Generate() {
 
 type mockMatcher struct{}
 
-func (m *mockMatcher) SetMatchString(s string)    {}
-func (m *mockMatcher) Match(k keygen.SSHKey) bool { return false }
+func (m *mockMatcher) SetMatchString(s string) error { return nil }
+func (m *mockMatcher) Match(k keygen.SSHKey) bool    { return false }
 
 func TestRegistry(t *testing.T) {
 	name := "mock"
@@ -68,7 +80,9 @@ func randSeq(n int) []byte {
 
 func BenchmarkMatchIgnorecase(b *testing.B) {
 	m := ignorecase.New()
-	m.SetMatchString("abcdef")
+	if err := m.SetMatchString("abcdef"); err != nil {
+		b.Fatal(err)
+	}
 	var k testKey
 	b.ResetTimer()
 
@@ -80,7 +94,71 @@ func BenchmarkMatchIgnorecase(b *testing.B) {
 
 func BenchmarkMatchIgnorecaseED25519(b *testing.B) {
 	m := ignorecaseed25519.New()
-	m.SetMatchString("abcdef")
+	if err := m.SetMatchString("abcdef"); err != nil {
+		b.Fatal(err)
+	}
+	var k testKey
+	b.ResetTimer()
+
+	for b.Loop() {
+		k = randSeq(100)
+		m.Match(k)
+	}
+}
+
+func BenchmarkMatchIgnorecaseED25519Anchored(b *testing.B) {
+	m := ignorecaseed25519.New()
+	if err := m.SetMatchString("abcdef"); err != nil {
+		b.Fatal(err)
+	}
+	if err := m.SetAnchor(ed25519.New(crand.Reader).Layout(), layout.AnchorStart, 0); err != nil {
+		b.Fatal(err)
+	}
+	var k testKey
+	b.ResetTimer()
+
+	for b.Loop() {
+		k = randSeq(100)
+		m.Match(k)
+	}
+}
+
+func BenchmarkMatchBitmaskIgnorecaseAnchored(b *testing.B) {
+	m := bitmask.NewIgnoreCase()
+	if err := m.SetMatchString("abcdef"); err != nil {
+		b.Fatal(err)
+	}
+	k := ed25519.New(crand.Reader)
+	if err := m.SetAnchor(k.Layout(), layout.AnchorStart, 1); err != nil {
+		b.Fatal(err)
+	}
+	k.Generate()
+	b.ResetTimer()
+
+	for b.Loop() {
+		m.Match(k)
+	}
+}
+
+func BenchmarkMatchExact(b *testing.B) {
+	m := exact.New()
+	if err := m.SetMatchString("abcdef"); err != nil {
+		b.Fatal(err)
+	}
+	var k testKey
+	b.ResetTimer()
+
+	for b.Loop() {
+		k = randSeq(100)
+		m.Match(k)
+	}
+}
+
+func BenchmarkMatchExactED25519(b *testing.B) {
+	m := exacted25519.New()
+	if err := m.SetMatchString("abcdef"); err != nil {
+		b.Fatal(err)
+	}
 	var k testKey
 	b.ResetTimer()
 
@@ -89,3 +167,94 @@ func BenchmarkMatchIgnorecaseED25519(b *testing.B) {
 		m.Match(k)
 	}
 }
+
+func BenchmarkMatchRegex(b *testing.B) {
+	for name, re := range map[string]string{
+		"prefilter": "^AAAAC3NzaC1lZDI1NTE5AAAAI[A-Z]{0,2}corp",
+		"literal":   "[0-9]corp[0-9]",
+		"none":      "(dev|ops)[0-9]{3}",
+	} {
+		b.Run(name, func(b *testing.B) {
+			m := regex.New()
+			if err := m.SetMatchString(re); err != nil {
+				b.Fatal(err)
+			}
+			k := ed25519.New(crand.Reader)
+			k.Generate()
+			b.ResetTimer()
+
+			for b.Loop() {
+				m.Match(k)
+			}
+		})
+	}
+}
+
+func BenchmarkMatchMulti(b *testing.B) {
+	for _, n := range []int{1, 50, 500} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			patterns := make([]string, n)
+			for i := range patterns {
+				patterns[i] = string(randSeq(6))
+			}
+			m := multi.New()
+			if err := m.SetMatchString(strings.Join(patterns, "\n")); err != nil {
+				b.Fatal(err)
+			}
+			k := ed25519.New(crand.Reader)
+			k.Generate()
+			b.ResetTimer()
+
+			for b.Loop() {
+				m.Match(k)
+			}
+		})
+	}
+}
+
+func BenchmarkMatchDictionary(b *testing.B) {
+	words := make([]string, 300000)
+	for i := range words {
+		words[i] = string(randSeq(4 + rand.IntN(8))) // #nosec G404
+	}
+	m := dictionary.New()
+	m.SetMinLength(6)
+	if err := m.SetMatchString(strings.Join(words, "\n")); err != nil {
+		b.Fatal(err)
+	}
+	k := ed25519.New(crand.Reader)
+	k.Generate()
+	b.ResetTimer()
+
+	for b.Loop() {
+		m.Match(k)
+	}
+}
+
+func BenchmarkMatchFingerprintSHA256(b *testing.B) {
+	m := fingerprint.New()
+	if err := m.SetMatchString("abcdef"); err != nil {
+		b.Fatal(err)
+	}
+	k := ed25519.New(crand.Reader)
+	k.Generate()
+	b.ResetTimer()
+
+	for b.Loop() {
+		m.Match(k)
+	}
+}
+
+func BenchmarkMatchRandomart(b *testing.B) {
+	m := fingerprint.NewRandomart()
+	if err := m.SetMatchString("|!!!!!!!!!!!!!!!!!|"); err != nil {
+		b.Fatal(err)
+	}
+	k := ed25519.New(crand.Reader)
+	k.Generate()
+	b.ResetTimer()
+
+	for b.Loop() {
+		m.Match(k)
+	}
+}
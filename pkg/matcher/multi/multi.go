@@ -0,0 +1,246 @@
+package multi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+// multiMatcher matches if the base64 part of the public key contains any of a
+// list of patterns, one per line of the match string. Empty lines and lines
+// starting with "#" are skipped.
+//
+// The patterns are compiled into an Aho-Corasick automaton, so a key is
+// scanned once whatever the number of patterns. Patterns can be removed while
+// keys are matched, the automaton is then rebuilt and swapped atomically.
+type multiMatcher struct {
+	ignoreCase bool
+	keyLayout  layout.Layout
+	// from and to are the part of the public key that depends on the key,
+	// where the patterns are looked for. to is -1 if the key layout is not
+	// known, then the whole base64 part is scanned.
+	from, to int
+	// mu serializes changes to the automaton, Match only loads it.
+	mu        sync.Mutex
+	automaton atomic.Pointer[automaton]
+}
+
+type automaton struct {
+	patterns []string
+	// classes maps every byte to a column of next. Bytes that are in no
+	// pattern share column 0.
+	classes  [256]uint16
+	nClasses int
+	// next is the transition table of the automaton, nClasses entries per
+	// state. State 0 is the start.
+	next []int32
+	// out is the index+1 of the longest pattern that ends in each state, or
+	// 0 if none does.
+	out []int32
+}
+
+func New() *multiMatcher {
+	m := &multiMatcher{to: -1}
+	m.automaton.Store(build(nil, false))
+	return m
+}
+
+// NewIgnoreCase returns a matcher that matches letters of either case.
+func NewIgnoreCase() *multiMatcher {
+	m := &multiMatcher{ignoreCase: true, to: -1}
+	m.automaton.Store(build(nil, true))
+	return m
+}
+
+func (m *multiMatcher) SetMatchString(matchString string) error {
+	var patterns []string
+	var err error
+	n := 0
+	for line := range strings.Lines(matchString) {
+		n++
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if e := layout.CheckBase64(line); e != nil && err == nil {
+			err = fmt.Errorf("line %d: %w", n, e)
+		}
+		patterns = append(patterns, line)
+	}
+	if len(patterns) == 0 {
+		err = errors.New("no patterns")
+	}
+	if err != nil {
+		patterns = nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.automaton.Store(build(patterns, m.ignoreCase))
+	return err
+}
+
+// SetAnchor gives the layout of the key type, to estimate the difficulty and
+// skip the fixed header. The patterns can be anywhere in the key, so only
+// AnchorAny is supported.
+func (m *multiMatcher) SetAnchor(l layout.Layout, anchor layout.Anchor, _ int) error {
+	if anchor != layout.AnchorAny {
+		return fmt.Errorf("anchor %s is not supported, patterns match anywhere in the key", anchor)
+	}
+	for _, pattern := range m.automaton.Load().patterns {
+		if err := l.Fits(l.Start, pattern, m.ignoreCase); err != nil {
+			return err
+		}
+	}
+	m.keyLayout, m.from, m.to = l, l.Start, l.End
+	return nil
+}
+
+// Patterns returns the patterns that are left to match.
+func (m *multiMatcher) Patterns() []string {
+	return slices.Clone(m.automaton.Load().patterns)
+}
+
+// Remove stops matching pattern. It reports false if the pattern was already
+// removed, or never there.
+func (m *multiMatcher) Remove(pattern string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	patterns := m.automaton.Load().patterns
+	i := slices.Index(patterns, pattern)
+	if i < 0 {
+		return false
+	}
+	m.automaton.Store(build(slices.Delete(slices.Clone(patterns), i, i+1), m.ignoreCase))
+	return true
+}
+
+// build compiles the patterns into an automaton.
+func build(patterns []string, ignoreCase bool) *automaton {
+	a := &automaton{patterns: patterns, nClasses: 1}
+	for _, p := range patterns {
+		for _, c := range []byte(p) {
+			if a.classes[c] != 0 {
+				continue
+			}
+			a.classes[c] = uint16(a.nClasses) //nolint:gosec // There are at most 257 classes.
+			if ignoreCase {
+				a.classes[foldByte(c)] = a.classes[c]
+			}
+			a.nClasses++
+		}
+	}
+
+	// The trie of the patterns, missing transitions are 0.
+	a.next = make([]int32, a.nClasses)
+	a.out = []int32{0}
+	for i, p := range patterns {
+		state := int32(0)
+		for _, c := range []byte(p) {
+			t := &a.next[int(state)*a.nClasses+int(a.classes[c])]
+			if *t == 0 {
+				*t = int32(len(a.out)) //nolint:gosec // The number of states is bounded by the pattern length.
+				a.next = append(a.next, make([]int32, a.nClasses)...)
+				a.out = append(a.out, 0)
+			}
+			state = *t
+		}
+		if a.out[state] == 0 {
+			a.out[state] = int32(i + 1) //nolint:gosec // The number of patterns fits.
+		}
+	}
+
+	// Turn the trie into a DFA in breadth first order, so the failure state
+	// of every state is complete before it is used.
+	fail := make([]int32, len(a.out))
+	queue := []int32{0}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		row := a.next[int(s)*a.nClasses : int(s+1)*a.nClasses]
+		for c, t := range row {
+			if t == 0 {
+				if s != 0 {
+					row[c] = a.next[int(fail[s])*a.nClasses+c]
+				}
+				continue
+			}
+			if s != 0 {
+				fail[t] = a.next[int(fail[s])*a.nClasses+c]
+			}
+			if a.out[t] == 0 {
+				a.out[t] = a.out[fail[t]]
+			}
+			queue = append(queue, t)
+		}
+	}
+	return a
+}
+
+func (m *multiMatcher) Match(s keygen.SSHKey) bool {
+	_, _, ok := m.automaton.Load().find(s.SSHPubkey(), m.from, m.to)
+	return ok
+}
+
+// Locate returns the pattern that matched the key, and its offset in the
+// public key.
+func (m *multiMatcher) Locate(s keygen.SSHKey) (string, int, bool) {
+	return m.automaton.Load().find(s.SSHPubkey(), m.from, m.to)
+}
+
+// find returns the pattern that ends first in pubK[from:to], or in the base64
+// part of pubK if to is -1.
+func (a *automaton) find(pubK []byte, from, to int) (string, int, bool) {
+	start, end := bytes.IndexByte(pubK, ' ')+1, len(pubK)
+	if to >= 0 {
+		if to > len(pubK) {
+			return "", 0, false
+		}
+		start, end = from, to
+	}
+	state := int32(0)
+	for i := start; i < end; i++ {
+		c := pubK[i]
+		if c == ' ' || c == '\n' {
+			break
+		}
+		state = a.next[int(state)*a.nClasses+int(a.classes[c])]
+		if p := a.out[state]; p != 0 {
+			pattern := a.patterns[p-1]
+			return pattern, i + 1 - len(pattern), true
+		}
+	}
+	return "", 0, false
+}
+
+// Difficulty returns the expected number of keys to test until any of the
+// patterns matches. Without a key layout it is estimated for ed25519 keys.
+func (m *multiMatcher) Difficulty() float64 {
+	l := m.keyLayout.OrEd25519()
+	p := 0.0
+	for _, pattern := range m.automaton.Load().patterns {
+		p += 1 / l.Difficulty(-1, pattern, m.ignoreCase)
+	}
+	if p == 0 {
+		return math.Inf(1)
+	}
+	return 1 / p
+}
+
+// foldByte returns the other case of an ASCII letter, or c.
+func foldByte(c byte) byte {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return c - 'a' + 'A'
+	case c >= 'A' && c <= 'Z':
+		return c + 'a' - 'A'
+	}
+	return c
+}
@@ -0,0 +1,249 @@
+package multi
+
+import (
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ecdsa"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/ed25519"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+type mockSSHKey struct {
+	pubkey []byte
+}
+
+func (m *mockSSHKey) SSHPubkey() []byte {
+	return m.pubkey
+}
+
+func (m *mockSSHKey) SSHPrivkey() []byte {
+	return nil
+}
+
+func (m *mockSSHKey) Generate() {}
+
+func TestMultiMatcher(t *testing.T) {
+	m := New()
+	if err := m.SetMatchString("# Team handles\nalice\n\n  bob  \nlice123\nCarol\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(m.Patterns(), ","); got != "alice,bob,lice123,Carol" {
+		t.Errorf("Unexpected patterns %s", got)
+	}
+
+	testCases := []struct {
+		pubkey  string
+		pattern string
+		offset  int
+	}{
+		{"ssh-ed25519 AAAAxbobx\n", "bob", 17},
+		{"ssh-ed25519 AAAAalice123\n", "alice", 16},
+		{"ssh-ed25519 AAAAxlice123\n", "lice123", 17},
+		{"ssh-ed25519 AAAAaliclice123\n", "lice123", 20},
+		{"ssh-ed25519 AAAACarol\n", "Carol", 16},
+		{"ssh-ed25519 AAAAcarol\n", "", 0},
+		{"ssh-ed25519 AAAAalic\n", "", 0},
+		// Only the base64 part is searched.
+		{"bob-ed25519 AAAA\n", "", 0},
+		{"ssh-ed25519 AAAA bob\n", "", 0},
+	}
+	for _, tc := range testCases {
+		key := &mockSSHKey{pubkey: []byte(tc.pubkey)}
+		if m.Match(key) != (tc.pattern != "") {
+			t.Errorf("Expected match=%v for pubkey %q", tc.pattern != "", tc.pubkey)
+		}
+		pattern, offset, ok := m.Locate(key)
+		if ok != (tc.pattern != "") || pattern != tc.pattern || offset != tc.offset {
+			t.Errorf("Expected %q at %d for pubkey %q, got %q at %d", tc.pattern, tc.offset, tc.pubkey, pattern, offset)
+		}
+	}
+}
+
+func TestMultiMatcherIgnoreCase(t *testing.T) {
+	m := NewIgnoreCase()
+	if err := m.SetMatchString("Carol\nDAVE"); err != nil {
+		t.Fatal(err)
+	}
+	for pubkey, match := range map[string]bool{
+		"ssh-ed25519 AAAAcArOl": true,
+		"ssh-ed25519 AAAAdave":  true,
+		"ssh-ed25519 AAAAdav":   false,
+	} {
+		if m.Match(&mockSSHKey{pubkey: []byte(pubkey)}) != match {
+			t.Errorf("Expected match=%v for pubkey %q", match, pubkey)
+		}
+	}
+}
+
+func TestNoPatterns(t *testing.T) {
+	m := New()
+	if err := m.SetMatchString("# nothing\n\n"); err == nil {
+		t.Error("Expected an error without patterns")
+	}
+	if m.Match(&mockSSHKey{pubkey: []byte("ssh-ed25519 AAAA")}) {
+		t.Error("Expected no match without patterns")
+	}
+}
+
+func TestInvalidPattern(t *testing.T) {
+	m := New()
+	err := m.SetMatchString("alice\n# bob_smith\nbob_smith\n")
+	if err == nil || !strings.Contains(err.Error(), `line 3: "bob_smith" can never match`) {
+		t.Errorf("Expected an error for line 3, got %v", err)
+	}
+	if m.Match(&mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAalice")}) {
+		t.Error("Expected no match with an invalid pattern")
+	}
+}
+
+func TestDifficulty(t *testing.T) {
+	m := New()
+	if err := m.SetMatchString("ab\ncd"); err != nil {
+		t.Fatal(err)
+	}
+	// Without a key layout it is estimated for ed25519 keys.
+	ed := ed25519.New(crand.Reader).Layout()
+	want := 1 / (1/ed.Difficulty(-1, "ab", false) + 1/ed.Difficulty(-1, "cd", false))
+	if d := m.Difficulty(); d != want || math.Abs(d/(64*64/(2*42.0))-1) > 0.1 {
+		t.Errorf("Expected about 64^2/(2*42) keys as for ed25519, got %v", d)
+	}
+	m = NewIgnoreCase()
+	if err := m.SetMatchString("a1"); err != nil {
+		t.Fatal(err)
+	}
+	if d := m.Difficulty(); d != ed.Difficulty(-1, "a1", true) || math.Abs(d/(32*64/42.0)-1) > 0.1 {
+		t.Errorf("Expected about 32*64/42 keys as for ed25519, got %v", d)
+	}
+	if err := m.SetMatchString(strings.Repeat("a", 44)); err != nil {
+		t.Fatal(err)
+	}
+	if d := m.Difficulty(); !math.IsInf(d, 1) {
+		t.Errorf("Expected a pattern longer than the key to never match, got %v", d)
+	}
+}
+
+func TestAnchor(t *testing.T) {
+	m := NewIgnoreCase()
+	if err := m.SetMatchString("ab\ncd"); err != nil {
+		t.Fatal(err)
+	}
+	ed := ed25519.New(crand.Reader).Layout()
+	if err := m.SetAnchor(ed, layout.AnchorAny, 0); err != nil {
+		t.Fatal(err)
+	}
+	want := 1 / (2 / ed.Difficulty(-1, "ab", true))
+	if d := m.Difficulty(); d != want {
+		t.Errorf("Expected %v keys for ed25519, got %v", want, d)
+	}
+
+	// The longer nistp521 key has more places for the patterns.
+	if err := m.SetAnchor(ecdsa.New(crand.Reader, elliptic.P521()).Layout(), layout.AnchorAny, 0); err != nil {
+		t.Fatal(err)
+	}
+	if d := m.Difficulty(); d >= want/3 {
+		t.Errorf("Expected fewer keys for nistp521 than %v, got %v", want/3, d)
+	}
+
+	if err := m.SetAnchor(ed, layout.AnchorStart, 0); err == nil {
+		t.Error("Expected an error for the start anchor")
+	}
+
+	// The fixed header is the same for every key and never matches.
+	if err := m.SetMatchString("nzac\nteam"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAnchor(ed, layout.AnchorAny, 0); err != nil {
+		t.Fatal(err)
+	}
+	key := &mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMyTeamAbcdefghijklmnopqrstuvwxyz0123456789x\n")}
+	if pattern, offset, ok := m.Locate(key); !ok || pattern != "team" || offset != 39 {
+		t.Errorf("Expected team at 39, got %q at %d, %v", pattern, offset, ok)
+	}
+
+	if err := m.SetMatchString(strings.Repeat("a", 44)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAnchor(ed, layout.AnchorAny, 0); err == nil {
+		t.Error("Expected an error for a pattern longer than the key")
+	}
+}
+
+func TestMultiMatcherRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2)) //nolint:gosec // Reproducible test data.
+	word := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = "abcAB"[r.IntN(5)]
+		}
+		return string(b)
+	}
+	for range 200 {
+		patterns := make([]string, 1+r.IntN(8))
+		for i := range patterns {
+			patterns[i] = word(1 + r.IntN(4))
+		}
+		m := New()
+		if err := m.SetMatchString(strings.Join(patterns, "\n")); err != nil {
+			t.Fatal(err)
+		}
+		text := word(r.IntN(30))
+
+		// The pattern that ends first, the longest one if several do.
+		wantEnd, want := len(text)+1, ""
+		for _, p := range patterns {
+			if i := strings.Index(text, p); i >= 0 {
+				if end := i + len(p); end < wantEnd || end == wantEnd && len(p) > len(want) {
+					wantEnd, want = end, p
+				}
+			}
+		}
+		pattern, offset, ok := m.Locate(&mockSSHKey{pubkey: []byte("t " + text)})
+		if ok != (want != "") || pattern != want || ok && offset != 2+wantEnd-len(want) {
+			t.Fatalf("Expected %q ending at %d in %q for %q, got %q at %d", want, wantEnd, text, patterns, pattern, offset)
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	m := New()
+	if err := m.SetMatchString("alice\nbob\ncarol"); err != nil {
+		t.Fatal(err)
+	}
+	key := &mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAbobalice\n")}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for range 1000 {
+				m.Match(key)
+			}
+		})
+	}
+	if pattern, _, _ := m.Locate(key); pattern != "bob" {
+		t.Errorf("Expected bob to match first, got %q", pattern)
+	}
+	if !m.Remove("bob") {
+		t.Error("Expected bob to be removed")
+	}
+	if m.Remove("bob") {
+		t.Error("Expected bob to be removed only once")
+	}
+	wg.Wait()
+
+	if pattern, _, _ := m.Locate(key); pattern != "alice" {
+		t.Errorf("Expected alice to match after bob was removed, got %q", pattern)
+	}
+	if got := strings.Join(m.Patterns(), ","); got != "alice,carol" {
+		t.Errorf("Unexpected remaining patterns %s", got)
+	}
+	m.Remove("alice")
+	if m.Match(key) {
+		t.Error("Expected no match after all patterns in the key were removed")
+	}
+}
@@ -0,0 +1,159 @@
+package regex
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"unicode/utf8"
+
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen"
+	"github.com/Mattias-/vanity-ssh-keygen/pkg/keygen/layout"
+)
+
+// regexMatcher matches a Go regular expression against the base64 part of
+// the public key, so "^" is the start of the base64 blob and "$" its end.
+//
+// Running the regexp for every key is slow, so the literals that every match
+// has to contain are extracted from it and looked for first.
+type regexMatcher struct {
+	ignoreCase bool
+	re         *regexp.Regexp
+	literals   []literal
+}
+
+// literal is a string that every match contains.
+type literal struct {
+	// s is lower case if foldCase is set.
+	s        []byte
+	foldCase bool
+	// prefix is set if s starts every match at the start of the blob.
+	prefix bool
+}
+
+func New() *regexMatcher {
+	return &regexMatcher{}
+}
+
+// NewIgnoreCase returns a matcher that matches letters of either case, like
+// the regular expression flag "(?i)".
+func NewIgnoreCase() *regexMatcher {
+	return &regexMatcher{ignoreCase: true}
+}
+
+func (m *regexMatcher) SetMatchString(matchString string) error {
+	m.re, m.literals = nil, nil
+	if m.ignoreCase {
+		matchString = "(?i)" + matchString
+	}
+	re, err := regexp.Compile(matchString)
+	if err != nil {
+		return err
+	}
+	parsed, err := syntax.Parse(matchString, syntax.Perl)
+	if err != nil {
+		return err
+	}
+	literals := requiredLiterals(parsed.Simplify(), nil)
+	for _, l := range literals {
+		if err := layout.CheckBase64(string(l.s)); err != nil {
+			return fmt.Errorf("every match contains %q: %w", l.s, err)
+		}
+	}
+	m.re, m.literals = re, literals
+	return nil
+}
+
+func (m *regexMatcher) Match(s keygen.SSHKey) bool {
+	if m.re == nil {
+		return false
+	}
+	blob := base64Part(s.SSHPubkey())
+	for _, l := range m.literals {
+		if l.prefix {
+			if len(blob) < len(l.s) || !equal(blob[:len(l.s)], l.s, l.foldCase) {
+				return false
+			}
+		} else if !contains(blob, l.s, l.foldCase) {
+			return false
+		}
+	}
+	return m.re.Match(blob)
+}
+
+// base64Part returns the base64 blob of an authorized_keys line.
+func base64Part(pubK []byte) []byte {
+	if i := bytes.IndexByte(pubK, ' '); i >= 0 {
+		pubK = pubK[i+1:]
+	}
+	if i := bytes.IndexAny(pubK, " \n"); i >= 0 {
+		pubK = pubK[:i]
+	}
+	return pubK
+}
+
+// requiredLiterals appends the literals that every match of re contains to
+// literals. Literals directly after "^" have to be at the start of the text.
+func requiredLiterals(re *syntax.Regexp, literals []literal) []literal {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return appendLiteral(literals, re, false)
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0], literals)
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return requiredLiterals(re.Sub[0], literals)
+		}
+	case syntax.OpConcat:
+		for i, sub := range re.Sub {
+			if i == 1 && sub.Op == syntax.OpLiteral &&
+				(re.Sub[0].Op == syntax.OpBeginText || re.Sub[0].Op == syntax.OpBeginLine) {
+				literals = appendLiteral(literals, sub, true)
+				continue
+			}
+			literals = requiredLiterals(sub, literals)
+		}
+	}
+	return literals
+}
+
+func appendLiteral(literals []literal, re *syntax.Regexp, prefix bool) []literal {
+	foldCase := re.Flags&syntax.FoldCase != 0
+	for _, r := range re.Rune {
+		if foldCase && r >= utf8.RuneSelf {
+			// Some non-ASCII letters fold to ASCII ones.
+			return literals
+		}
+	}
+	s := []byte(string(re.Rune))
+	if foldCase {
+		s = bytes.ToLower(s)
+	}
+	return append(literals, literal{s: s, foldCase: foldCase, prefix: prefix})
+}
+func equal(a, b []byte, foldCase bool) bool {
+	if !foldCase {
+		return bytes.Equal(a, b)
+	}
+	for i, c := range a {
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if c != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(b, substr []byte, foldCase bool) bool {
+	if !foldCase {
+		return bytes.Contains(b, substr)
+	}
+	for i := 0; i <= len(b)-len(substr); i++ {
+		if equal(b[i:i+len(substr)], substr, true) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package regex
+
+import (
+	"reflect"
+	"regexp/syntax"
+	"strings"
+	"testing"
+)
+
+type mockSSHKey struct {
+	pubkey []byte
+}
+
+func (m *mockSSHKey) SSHPubkey() []byte {
+	return m.pubkey
+}
+
+func (m *mockSSHKey) SSHPrivkey() []byte {
+	return nil
+}
+
+func (m *mockSSHKey) Generate() {}
+
+func TestRegexMatcher(t *testing.T) {
+	key := &mockSSHKey{pubkey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIABcorp0dev123xyzOps999abcdefghijklmnopqrstuvw\n")}
+
+	m := New()
+	for matchString, match := range map[string]bool{
+		`^AAAAC3NzaC1lZDI1NTE5AAAAI[A-Z]{0,2}corp`: true,
+		`^AAAAC3NzaC1lZDI1NTE5AAAAI[A-Z]{0,1}corp`: false,
+		`(dev|ops)[0-9]{3}`:                        true,
+		`(dev|ops)[0-9]{4}`:                        false,
+		`Ops999`:                                   true,
+		`ops999`:                                   false,
+		`^ssh`:                                     false,
+		`uvw$`:                                     true,
+		`xyz$`:                                     false,
+		`(?i)OPS999`:                               true,
+	} {
+		if err := m.SetMatchString(matchString); err != nil {
+			t.Fatal(err)
+		}
+		if m.Match(key) != match {
+			t.Errorf("Expected match=%v for %q", match, matchString)
+		}
+	}
+
+	m = NewIgnoreCase()
+	for matchString, match := range map[string]bool{
+		`^aaaac3nzac1lzdi1ntE5AAAAI[a-z]{0,2}CORP`: true,
+		`OPS999`:       true,
+		`(DEV|xyz)123`: true,
+		`dev124`:       false,
+	} {
+		if err := m.SetMatchString(matchString); err != nil {
+			t.Fatal(err)
+		}
+		if m.Match(key) != match {
+			t.Errorf("Expected match=%v for %q ignoring case", match, matchString)
+		}
+	}
+}
+
+func TestInvalidRegex(t *testing.T) {
+	m := New()
+	if err := m.SetMatchString("(abc"); err == nil {
+		t.Error("Expected an error for an invalid regular expression")
+	}
+	if m.Match(&mockSSHKey{pubkey: []byte("ssh-ed25519 abc")}) {
+		t.Error("Expected an invalid regular expression not to match")
+	}
+	// Every match contains "my_key", which is not base64.
+	if err := m.SetMatchString("(my_key|x)y"); err != nil {
+		t.Error(err)
+	}
+	if err := m.SetMatchString("my_key[0-9]"); err == nil || !strings.Contains(err.Error(), `try "my/key"`) {
+		t.Errorf("Expected an error for a literal that is not base64, got %v", err)
+	}
+}
+
+func TestRequiredLiterals(t *testing.T) {
+	for _, tt := range []struct {
+		re       string
+		literals []literal
+	}{
+		{`^AAAAC3NzaC1lZDI1NTE5AAAAI[A-Z]{0,2}corp`, []literal{
+			{s: []byte("AAAAC3NzaC1lZDI1NTE5AAAAI"), prefix: true},
+			{s: []byte("corp")},
+		}},
+		{`[A-Z]corp[0-9]+`, []literal{{s: []byte("corp")}}},
+		{`x(corp)+y`, []literal{{s: []byte("x")}, {s: []byte("corp")}, {s: []byte("y")}}},
+		{`(dev|ops)[0-9]{3}`, nil},
+		{`(?i)^abc`, []literal{{s: []byte("abc"), foldCase: true, prefix: true}}},
+		{`a(?i:bcd)`, []literal{{s: []byte("a")}, {s: []byte("bcd"), foldCase: true}}},
+		{`(abc)?d`, []literal{{s: []byte("d")}}},
+	} {
+		parsed, err := syntax.Parse(tt.re, syntax.Perl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := requiredLiterals(parsed.Simplify(), nil); !reflect.DeepEqual(got, tt.literals) {
+			t.Errorf("Expected %+v for %q, got %+v", tt.literals, tt.re, got)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package pkcs8
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"testing"
+)
+
+// decrypt reverses the PBES2 encryption of MarshalPrivateKey.
+func decrypt(t *testing.T, der []byte, passphrase []byte) []byte {
+	t.Helper()
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		t.Fatal(err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		t.Fatalf("Unexpected algorithm %v", info.Algorithm.Algorithm)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		t.Fatal(err)
+	}
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		t.Fatal(err)
+	}
+	if !kdf.PRF.Algorithm.Equal(oidHMACWithSHA256) || !params.EncryptionScheme.Algorithm.Equal(oidAES256CBC) {
+		t.Fatalf("Unexpected PRF %v or cipher %v", kdf.PRF.Algorithm, params.EncryptionScheme.Algorithm)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		t.Fatal(err)
+	}
+	k, err := pbkdf2.Key(sha256.New, string(passphrase), kdf.Salt, kdf.IterationCount, kdf.KeyLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := aes.NewCipher(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(c, iv).CryptBlocks(plain, info.EncryptedData)
+	return plain[:len(plain)-int(plain[len(plain)-1])]
+}
+
+func TestMarshal(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, key := range map[string]crypto.Signer{"ed25519": edKey, "ecdsa": ecKey, "rsa": rsaKey} {
+		t.Run(name, func(t *testing.T) {
+			priv, err := MarshalPrivateKey(key, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			block, _ := pem.Decode(priv)
+			if block == nil || block.Type != "PRIVATE KEY" {
+				t.Fatal("Expected a PRIVATE KEY PEM block")
+			}
+			parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !parsed.(interface{ Equal(crypto.PrivateKey) bool }).Equal(key) {
+				t.Error("Private key does not match")
+			}
+
+			priv, err = MarshalPrivateKey(key, Options{Passphrase: []byte("secret"), Iterations: 1000})
+			if err != nil {
+				t.Fatal(err)
+			}
+			block, _ = pem.Decode(priv)
+			if block == nil || block.Type != "ENCRYPTED PRIVATE KEY" {
+				t.Fatal("Expected an ENCRYPTED PRIVATE KEY PEM block")
+			}
+			parsed, err = x509.ParsePKCS8PrivateKey(decrypt(t, block.Bytes, []byte("secret")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !parsed.(interface{ Equal(crypto.PrivateKey) bool }).Equal(key) {
+				t.Error("Decrypted private key does not match")
+			}
+
+			pub, err := MarshalPublicKey(key.Public())
+			if err != nil {
+				t.Fatal(err)
+			}
+			block, _ = pem.Decode(pub)
+			if block == nil || block.Type != "PUBLIC KEY" {
+				t.Fatal("Expected a PUBLIC KEY PEM block")
+			}
+			parsedPub, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !parsedPub.(interface{ Equal(crypto.PublicKey) bool }).Equal(key.Public()) {
+				t.Error("Public key does not match")
+			}
+		})
+	}
+}
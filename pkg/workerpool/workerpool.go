@@ -3,6 +3,7 @@ package workerpool
 import (
 	"context"
 	"log/slog"
+	"math"
 	"time"
 
 	"go.opentelemetry.io/otel"
@@ -18,21 +19,71 @@ type Worker[R any] interface {
 type WorkerPool[R any] struct {
 	Workers []Worker[R]
 	Results R
-	start   time.Time
+	// ElapsedOffset is the time spent before the search was resumed, so the
+	// stats cover the whole search. Resumed workers count their earlier
+	// attempts as well.
+	ElapsedOffset time.Duration
+	start         time.Time
 }
 
 type WorkerPoolStats struct {
 	Workers int
 	Count   int64
 	Elapsed time.Duration
+	// Probability is the probability that a key matches, 0 if it is not
+	// known. It is set by the caller, the pool does not know the matcher.
+	Probability float64
 }
 
+// Log logs the rate, and the expected search time if the probability is
+// known.
 func (wps WorkerPoolStats) Log() {
-	slog.Info("Tested keys",
+	attrs := []any{
 		slog.Duration("time", wps.Elapsed),
 		slog.Int64("tested", wps.Count),
-		slog.Float64("kKeys/s", float64(wps.Count)/wps.Elapsed.Seconds()/1000),
-	)
+		slog.Float64("kKeys/s", wps.Rate()/1000),
+	}
+	if wps.Probability > 0 {
+		attrs = append(attrs,
+			slog.Float64("expected_keys", 1/wps.Probability),
+			slog.Duration("eta_p50", wps.ETA(0.5).Round(time.Second)),
+			slog.Duration("eta_p90", wps.ETA(0.9).Round(time.Second)),
+			slog.Float64("found_probability", wps.FoundProbability()),
+		)
+	}
+	slog.Info("Tested keys", attrs...)
+}
+
+// Rate returns the number of keys tested per second.
+func (wps WorkerPoolStats) Rate() float64 {
+	return float64(wps.Count) / wps.Elapsed.Seconds()
+}
+
+// ETA returns the time from now until a key is found with probability q, at
+// the current rate. Every key matches independently of the keys before it,
+// so the time does not get shorter the longer the search has gone on.
+func (wps WorkerPoolStats) ETA(q float64) time.Duration {
+	return seconds(math.Log1p(-q) / math.Log1p(-wps.Probability) / wps.Rate())
+}
+
+// ExpectedTime returns the mean time from now until a key is found, at the
+// current rate.
+func (wps WorkerPoolStats) ExpectedTime() time.Duration {
+	return seconds(1 / wps.Probability / wps.Rate())
+}
+
+// FoundProbability returns the probability that a key would have been found
+// by now, after Count keys. A low value means the search was unlucky so far.
+func (wps WorkerPoolStats) FoundProbability() float64 {
+	return -math.Expm1(float64(wps.Count) * math.Log1p(-wps.Probability))
+}
+
+// seconds converts s to a duration, the longest duration if it does not fit.
+func seconds(s float64) time.Duration {
+	if math.IsNaN(s) || s >= float64(math.MaxInt64)/float64(time.Second) {
+		return math.MaxInt64
+	}
+	return time.Duration(s * float64(time.Second))
 }
 
 func (wp *WorkerPool[R]) Start(ctx context.Context) {
@@ -72,6 +123,6 @@ func (wp *WorkerPool[R]) GetStats() *WorkerPoolStats {
 	return &WorkerPoolStats{
 		Workers: len(wp.Workers),
 		Count:   sum,
-		Elapsed: time.Since(wp.start),
+		Elapsed: time.Since(wp.start) + wp.ElapsedOffset,
 	}
 }
@@ -2,6 +2,7 @@ package workerpool
 
 import (
 	"context"
+	"math"
 	"testing"
 	"time"
 )
@@ -53,3 +54,48 @@ func TestWorkerPool(t *testing.T) {
 
 	stats.Log()
 }
+
+func TestWorkerPoolElapsedOffset(t *testing.T) {
+	wp := &WorkerPool[chan int]{
+		ElapsedOffset: time.Hour,
+	}
+	wp.Start(context.Background())
+	if elapsed := wp.GetStats().Elapsed; elapsed < time.Hour || elapsed > time.Hour+time.Minute {
+		t.Errorf("Expected elapsed time to include the offset, got %v", elapsed)
+	}
+}
+
+func TestWorkerPoolStatsETA(t *testing.T) {
+	wps := WorkerPoolStats{
+		Count:       1000,
+		Elapsed:     time.Second,
+		Probability: 1e-6,
+	}
+	for _, tt := range []struct {
+		name string
+		got  time.Duration
+		want time.Duration
+	}{
+		// ln(2)*1e6 keys at 1000 keys/s.
+		{"p50", wps.ETA(0.5), 693 * time.Second},
+		{"p90", wps.ETA(0.9), 2303 * time.Second},
+		{"expected", wps.ExpectedTime(), 1000 * time.Second},
+	} {
+		if tt.got.Round(time.Second) != tt.want {
+			t.Errorf("Expected %s of %v, got %v", tt.name, tt.want, tt.got)
+		}
+	}
+	if p := wps.FoundProbability(); p < 0.000999 || p > 0.001 {
+		t.Errorf("Expected a found probability of about 1e-3, got %v", p)
+	}
+
+	wps.Probability = 1e-30
+	if eta := wps.ETA(0.9); eta != math.MaxInt64 {
+		t.Errorf("Expected the longest duration for a hopeless search, got %v", eta)
+	}
+	wps.Count = 0
+	if eta := wps.ExpectedTime(); eta != math.MaxInt64 {
+		t.Errorf("Expected the longest duration without a rate, got %v", eta)
+	}
+	wps.Log()
+}